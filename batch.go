@@ -0,0 +1,136 @@
+package eet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultBatchParallelism is the number of receipts SendBatch sends
+// concurrently unless BatchOptions.Parallelism is set.
+const DefaultBatchParallelism = 8
+
+// ErrInFlight is returned when a message with the same uuid_zpravy is being
+// sent by the Dispatcher, the message is not sent twice.
+var ErrInFlight = errors.New("eet: message with the same uuid_zpravy is already being sent")
+
+// BatchOptions configures SendBatch.
+type BatchOptions struct {
+	// Parallelism is the number of receipts sent concurrently,
+	// DefaultBatchParallelism when zero.
+	Parallelism int
+	// Rate is the maximal number of requests started per second,
+	// unlimited when zero or above 1e9.
+	Rate float64
+	// Timeout bounds sending of each receipt, e.g. the 2 seconds required
+	// by law. Only ctx bounds it when zero.
+	Timeout time.Duration
+}
+
+// BatchResult is the Outcome of the receipt at Index of the batch.
+type BatchResult struct {
+	Index int
+	Outcome
+}
+
+// SendBatch submits receipts concurrently, e.g. a backlog collected during
+// an outage, and streams a BatchResult for every receipt in the order they
+// complete. The channel is closed when all receipts are done. Receipts not
+// started before ctx is done get its error without being signed.
+//
+// Requests share the keep-alive connections of the Dispatcher's HTTP client.
+// A receipt whose uuid_zpravy is already being sent gets ErrInFlight.
+func (d *Dispatcher) SendBatch(ctx context.Context, receipts []Receipt, opts BatchOptions) <-chan BatchResult {
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultBatchParallelism
+	}
+	if parallelism > len(receipts) {
+		parallelism = len(receipts)
+	}
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if opts.Rate > 0 {
+		// rates above one request per nanosecond cannot be ticked
+		if interval := time.Duration(float64(time.Second) / opts.Rate); interval > 0 {
+			ticker = time.NewTicker(interval)
+			tick = ticker.C
+		}
+	}
+
+	results := make(chan BatchResult, len(receipts))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results <- BatchResult{Index: i, Outcome: d.submitBatched(ctx, receipts[i], tick, opts.Timeout)}
+			}
+		}()
+	}
+	go func() {
+		for i := range receipts {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+		if ticker != nil {
+			ticker.Stop()
+		}
+		close(results)
+	}()
+	return results
+}
+
+// submitBatched waits for tick, if rate limited, and submits receipt.
+func (d *Dispatcher) submitBatched(ctx context.Context, receipt Receipt, tick <-chan time.Time, timeout time.Duration) Outcome {
+	if tick != nil {
+		select {
+		case <-tick:
+		case <-ctx.Done():
+			return Outcome{Err: contextError(ctx.Err())}
+		}
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return d.Submit(ctx, receipt)
+}
+
+// inFlight tracks uuid_zpravy of messages being sent.
+type inFlight struct {
+	mu    sync.Mutex
+	uuids map[UUIDType]struct{}
+}
+
+// acquire marks uuid as being sent, it returns ErrInFlight if it already is.
+// Empty uuid is not tracked.
+func (f *inFlight) acquire(uuid UUIDType) error {
+	if uuid == "" {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.uuids[uuid]; ok {
+		return ErrInFlight
+	}
+	if f.uuids == nil {
+		f.uuids = make(map[UUIDType]struct{})
+	}
+	f.uuids[uuid] = struct{}{}
+	return nil
+}
+
+func (f *inFlight) release(uuid UUIDType) {
+	if uuid == "" {
+		return
+	}
+	f.mu.Lock()
+	delete(f.uuids, uuid)
+	f.mu.Unlock()
+}
@@ -0,0 +1,332 @@
+package eet
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const nsXMLUrl = "http://www.w3.org/XML/1998/namespace"
+
+// xmlNode is an element of a parsed XML document. Unlike encoding/xml it keeps
+// prefixes and namespace declarations, which the canonical form depends on.
+type xmlNode struct {
+	parent   *xmlNode
+	prefix   string
+	local    string
+	space    string
+	decls    map[string]string
+	attrs    []xmlAttr
+	children []interface{} // *xmlNode, xml.CharData or xml.ProcInst
+}
+
+type xmlAttr struct {
+	prefix string
+	local  string
+	space  string
+	value  string
+}
+
+// parseXMLTree parses data into a tree of xmlNodes and returns the document element.
+func parseXMLTree(data []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var root, cur *xmlNode
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if cur == nil && root != nil {
+				return nil, fmt.Errorf("multiple document elements")
+			}
+			n := &xmlNode{parent: cur, prefix: t.Name.Space, local: t.Name.Local, decls: map[string]string{}}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					n.decls[a.Name.Local] = a.Value
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					n.decls[""] = a.Value
+				default:
+					n.attrs = append(n.attrs, xmlAttr{prefix: a.Name.Space, local: a.Name.Local, value: a.Value})
+				}
+			}
+			var ok bool
+			if n.space, ok = n.lookup(n.prefix); !ok {
+				return nil, fmt.Errorf("undeclared namespace prefix %q", n.prefix)
+			}
+			for i := range n.attrs {
+				if n.attrs[i].prefix == "" {
+					continue
+				}
+				if n.attrs[i].space, ok = n.lookup(n.attrs[i].prefix); !ok {
+					return nil, fmt.Errorf("undeclared namespace prefix %q", n.attrs[i].prefix)
+				}
+			}
+			if cur == nil {
+				root = n
+			} else {
+				cur.children = append(cur.children, n)
+			}
+			cur = n
+		case xml.EndElement:
+			if cur == nil || cur.prefix != t.Name.Space || cur.local != t.Name.Local {
+				return nil, fmt.Errorf("unexpected end element %s", t.Name.Local)
+			}
+			cur = cur.parent
+		case xml.CharData:
+			if cur != nil {
+				cur.children = append(cur.children, t.Copy())
+			}
+		case xml.ProcInst:
+			if cur != nil {
+				cur.children = append(cur.children, t.Copy())
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no document element")
+	}
+	if cur != nil {
+		return nil, fmt.Errorf("unclosed element %s", cur.local)
+	}
+	return root, nil
+}
+
+// lookup resolves prefix to the namespace URI in scope of the node.
+func (n *xmlNode) lookup(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return nsXMLUrl, true
+	}
+	for e := n; e != nil; e = e.parent {
+		if uri, ok := e.decls[prefix]; ok {
+			return uri, true
+		}
+	}
+	return "", prefix == ""
+}
+
+// child returns the first child element with the given namespace and local name.
+func (n *xmlNode) child(space, local string) *xmlNode {
+	for _, c := range n.children {
+		if e, ok := c.(*xmlNode); ok && e.space == space && e.local == local {
+			return e
+		}
+	}
+	return nil
+}
+
+// childrenNamed returns all child elements with the given namespace and local name.
+func (n *xmlNode) childrenNamed(space, local string) []*xmlNode {
+	var nodes []*xmlNode
+	for _, c := range n.children {
+		if e, ok := c.(*xmlNode); ok && e.space == space && e.local == local {
+			nodes = append(nodes, e)
+		}
+	}
+	return nodes
+}
+
+// attr returns the value of the attribute with the given namespace and local name.
+func (n *xmlNode) attr(space, local string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.space == space && a.local == local {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+// text returns the concatenated character data of the node's direct children.
+func (n *xmlNode) text() string {
+	var sb strings.Builder
+	for _, c := range n.children {
+		if cd, ok := c.(xml.CharData); ok {
+			sb.Write(cd)
+		}
+	}
+	return sb.String()
+}
+
+// id returns the wsu:Id or unqualified Id attribute of the node.
+func (n *xmlNode) id() (string, bool) {
+	if id, ok := n.attr(NsWsuUrl, "Id"); ok {
+		return id, true
+	}
+	return n.attr("", "Id")
+}
+
+// findByID returns all elements in the subtree whose id equals id.
+func (n *xmlNode) findByID(id string) []*xmlNode {
+	var nodes []*xmlNode
+	if v, ok := n.id(); ok && v == id {
+		nodes = append(nodes, n)
+	}
+	for _, c := range n.children {
+		if e, ok := c.(*xmlNode); ok {
+			nodes = append(nodes, e.findByID(id)...)
+		}
+	}
+	return nodes
+}
+
+// Canonicalize returns the exclusive XML canonicalization (xml-exc-c14n,
+// without comments) of the element of the XML document data whose wsu:Id or Id
+// attribute equals id, or of the document element when id is empty.
+// prefixList is the whitespace separated InclusiveNamespaces PrefixList.
+func Canonicalize(data []byte, id string, prefixList string) ([]byte, error) {
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing XML: %w", err)
+	}
+	n := root
+	if id != "" {
+		nodes := root.findByID(id)
+		if len(nodes) != 1 {
+			return nil, fmt.Errorf("expected exactly one element with Id %q, found %d", id, len(nodes))
+		}
+		n = nodes[0]
+	}
+	return excC14N(n, strings.Fields(prefixList)), nil
+}
+
+// excC14N returns the exclusive XML canonicalization (without comments) of
+// the subtree rooted at n. Prefixes listed in inclusive are treated according
+// to the InclusiveNamespaces PrefixList rules, "#default" denotes the default namespace.
+func excC14N(n *xmlNode, inclusive []string) []byte {
+	incl := make(map[string]bool, len(inclusive))
+	for _, p := range inclusive {
+		if p == "#default" {
+			p = ""
+		}
+		incl[p] = true
+	}
+	var buf bytes.Buffer
+	writeC14N(&buf, n, map[string]string{}, incl)
+	return buf.Bytes()
+}
+
+func writeC14N(buf *bytes.Buffer, n *xmlNode, rendered map[string]string, incl map[string]bool) {
+	candidates := map[string]bool{n.prefix: true}
+	for _, a := range n.attrs {
+		if a.prefix != "" && a.prefix != "xml" {
+			candidates[a.prefix] = true
+		}
+	}
+	for p := range incl {
+		candidates[p] = true
+	}
+
+	var decls []string
+	scope := rendered
+	for p := range candidates {
+		uri, ok := n.lookup(p)
+		if !ok || p == "xml" || rendered[p] == uri {
+			continue
+		}
+		if len(decls) == 0 {
+			scope = make(map[string]string, len(rendered)+1)
+			for k, v := range rendered {
+				scope[k] = v
+			}
+		}
+		scope[p] = uri
+		decls = append(decls, p)
+	}
+	sort.Strings(decls)
+
+	attrs := make([]xmlAttr, len(n.attrs))
+	copy(attrs, n.attrs)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	name := qualifiedName(n.prefix, n.local)
+	buf.WriteByte('<')
+	buf.WriteString(name)
+	for _, p := range decls {
+		if p == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + p + `="`)
+		}
+		escapeC14NAttr(buf, scope[p])
+		buf.WriteByte('"')
+	}
+	for _, a := range attrs {
+		buf.WriteString(" " + qualifiedName(a.prefix, a.local) + `="`)
+		escapeC14NAttr(buf, a.value)
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+	for _, c := range n.children {
+		switch c := c.(type) {
+		case *xmlNode:
+			writeC14N(buf, c, scope, incl)
+		case xml.CharData:
+			escapeC14NText(buf, string(c))
+		case xml.ProcInst:
+			buf.WriteString("<?" + c.Target)
+			if len(c.Inst) > 0 {
+				buf.WriteString(" " + string(c.Inst))
+			}
+			buf.WriteString("?>")
+		}
+	}
+	buf.WriteString("</" + name + ">")
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+func escapeC14NText(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+func escapeC14NAttr(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '"':
+			buf.WriteString("&quot;")
+		case '\t':
+			buf.WriteString("&#x9;")
+		case '\n':
+			buf.WriteString("&#xA;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package eet
+
+import (
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		id         string
+		prefixList string
+		want       string
+	}{
+		{
+			name:  "attribute order, unused namespaces and empty elements",
+			input: `<a:root xmlns:a="urn:a" xmlns:b="urn:b" z="1" a:y="2" b="3"><child/>  text &amp; <![CDATA[<x>]]></a:root>`,
+			want:  `<a:root xmlns:a="urn:a" b="3" z="1" a:y="2"><child></child>  text &amp; &lt;x&gt;</a:root>`,
+		},
+		{
+			name:  "subset inherits visibly utilized namespaces",
+			input: `<s:Envelope xmlns:s="urn:s" xmlns:u="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd" xmlns:x="urn:x"><s:Body u:Id="b"><p>v</p></s:Body></s:Envelope>`,
+			id:    "b",
+			want:  `<s:Body xmlns:s="urn:s" xmlns:u="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd" u:Id="b"><p>v</p></s:Body>`,
+		},
+		{
+			name:       "inclusive prefix list",
+			input:      `<s:Envelope xmlns:s="urn:s" xmlns:u="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd" xmlns:x="urn:x"><s:Body u:Id="b"><p>v</p></s:Body></s:Envelope>`,
+			id:         "b",
+			prefixList: "x",
+			want:       `<s:Body xmlns:s="urn:s" xmlns:u="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd" xmlns:x="urn:x" u:Id="b"><p>v</p></s:Body>`,
+		},
+		{
+			name:  "default namespace",
+			input: `<r xmlns="urn:d"><c xmlns="urn:d"/><e xmlns=""/></r>`,
+			want:  `<r xmlns="urn:d"><c></c><e xmlns=""></e></r>`,
+		},
+		{
+			name:  "attribute escaping",
+			input: `<r a="&quot;&#10;&lt;&gt;"/>`,
+			want:  `<r a="&quot;&#xA;&lt;>"></r>`,
+		},
+		{
+			name:  "declaration and comments",
+			input: "<?xml version=\"1.0\"?>\n<!-- c --><r><!-- c -->x</r>",
+			want:  `<r>x</r>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonicalize([]byte(tt.input), tt.id, tt.prefixList)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Canonicalize() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package eet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Castka is an exact amount of money in halers (1/100 CZK). Amounts are
+// added and compared without the rounding errors of float64.
+type Castka int64
+
+// CastkaFromHalere returns the amount of h halers.
+func CastkaFromHalere(h int64) Castka {
+	return Castka(h)
+}
+
+// CastkaFromKoruny returns the amount of k whole crowns.
+func CastkaFromKoruny(k int64) Castka {
+	return Castka(k * 100)
+}
+
+// CastkaFromFloat returns the amount rounded to halers.
+//
+// Deprecated: floats cannot represent most amounts exactly, use ParseCastka,
+// CastkaFromHalere or CastkaFromKoruny.
+func CastkaFromFloat(f float64) Castka {
+	return Castka(math.Round(f * 100))
+}
+
+// ParseCastka parses an amount in crowns with at most two decimal places,
+// e.g. "1234", "-0.5" or "99,90". Spaces used as thousands separators are ignored.
+func ParseCastka(s string) (Castka, error) {
+	str := strings.Replace(strings.TrimSpace(s), " ", "", -1)
+	str = strings.Replace(str, ",", ".", 1)
+	negative := strings.HasPrefix(str, "-")
+	if negative || strings.HasPrefix(str, "+") {
+		str = str[1:]
+	}
+
+	whole, frac := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		whole, frac = str[:i], str[i+1:]
+	}
+	if whole == "" && frac == "" || len(frac) > 2 || !digitsOnly(whole) || !digitsOnly(frac) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	koruny, halere := int64(0), int64(0)
+	var err error
+	if whole != "" {
+		if koruny, err = strconv.ParseInt(whole, 10, 64); err != nil || koruny > math.MaxInt64/100-1 {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+	if frac != "" {
+		halere, _ = strconv.ParseInt((frac + "0")[:2], 10, 64)
+	}
+	c := Castka(koruny*100 + halere)
+	if negative {
+		c = -c
+	}
+	return c, nil
+}
+
+// MustParseCastka is like ParseCastka but panics on invalid input, e.g. for constants.
+func MustParseCastka(s string) Castka {
+	c, err := ParseCastka(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func digitsOnly(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// SumCastka returns the sum of amounts.
+func SumCastka(amounts ...Castka) Castka {
+	var sum Castka
+	for _, a := range amounts {
+		sum += a
+	}
+	return sum
+}
+
+func (c Castka) Add(o Castka) Castka {
+	return c + o
+}
+
+func (c Castka) Sub(o Castka) Castka {
+	return c - o
+}
+
+func (c Castka) Neg() Castka {
+	return -c
+}
+
+// ErrCastkaOverflow is returned by MulChecked and PercentChecked when the
+// result does not fit into Castka.
+var ErrCastkaOverflow = errors.New("eet: amount overflows")
+
+// Mul returns the amount multiplied by n, e.g. the price of n pieces.
+// The result wraps around on overflow, see MulChecked.
+func (c Castka) Mul(n int64) Castka {
+	return c * Castka(n)
+}
+
+// MulChecked is like Mul but returns ErrCastkaOverflow when the result does not fit.
+func (c Castka) MulChecked(n int64) (Castka, error) {
+	if c == 0 || n == 0 {
+		return 0, nil
+	}
+	p := int64(c) * n
+	if p/n != int64(c) || n == -1 && c == math.MinInt64 {
+		return 0, fmt.Errorf("%w: %s * %d", ErrCastkaOverflow, c, n)
+	}
+	return Castka(p), nil
+}
+
+// Percent returns rate percent of the amount rounded half away from zero to halers,
+// e.g. the VAT of a tax base. The result is wrong when the amount multiplied
+// by rate overflows, see PercentChecked.
+func (c Castka) Percent(rate int64) Castka {
+	return percent(int64(c) * rate)
+}
+
+// PercentChecked is like Percent but returns ErrCastkaOverflow when the
+// amount multiplied by rate does not fit.
+func (c Castka) PercentChecked(rate int64) (Castka, error) {
+	p, err := c.MulChecked(rate)
+	if err != nil || p > math.MaxInt64-50 || p < math.MinInt64+50 {
+		return 0, fmt.Errorf("%w: %d %% of %s", ErrCastkaOverflow, rate, c)
+	}
+	return percent(int64(p)), nil
+}
+
+// percent rounds p hundredths of a haler half away from zero to halers.
+func percent(p int64) Castka {
+	if p < 0 {
+		return Castka(-((-p + 50) / 100))
+	}
+	return Castka((p + 50) / 100)
+}
+
+// Abs returns the absolute value of the amount.
+func (c Castka) Abs() Castka {
+	if c < 0 {
+		return -c
+	}
+	return c
+}
+
+// Halere returns the amount in halers.
+func (c Castka) Halere() int64 {
+	return int64(c)
+}
+
+// Float64 returns the amount in crowns as float64, for display only.
+func (c Castka) Float64() float64 {
+	return float64(c) / 100
+}
+
+// String formats the amount in crowns with two decimal places as used by EET, e.g. "-0.05".
+func (c Castka) String() string {
+	sign := ""
+	h := int64(c)
+	if h < 0 {
+		sign = "-"
+		h = -h
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, h/100, h%100)
+}
+
+func (c Castka) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Castka) UnmarshalText(text []byte) error {
+	parsed, err := ParseCastka(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
@@ -0,0 +1,91 @@
+package eet
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseCastka(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Castka
+		str   string
+		err   bool
+	}{
+		{input: "0", want: 0, str: "0.00"},
+		{input: "1234.5", want: 123450, str: "1234.50"},
+		{input: "-0.05", want: -5, str: "-0.05"},
+		{input: "1 299,90", want: 129990, str: "1299.90"},
+		{input: ".1", want: 10, str: "0.10"},
+		{input: "1.005", err: true},
+		{input: "12a", err: true},
+		{input: "-", err: true},
+		{input: "+5", want: 500, str: "5.00"},
+		{input: "-+5", err: true},
+		{input: "+-5", err: true},
+		{input: "--5", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCastka(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseCastka() error = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if got != tt.want || got.String() != tt.str {
+				t.Errorf("ParseCastka() = %d (%s), want %d (%s)", got, got, tt.want, tt.str)
+			}
+		})
+	}
+}
+
+func TestCastka_Arithmetic(t *testing.T) {
+	var sum Castka
+	for i := 0; i < 10; i++ {
+		sum = sum.Add(MustParseCastka("0.10"))
+	}
+	if sum != CastkaFromKoruny(1) {
+		t.Errorf("sum of ten 0.10 = %s, want 1.00", sum)
+	}
+	if got := MustParseCastka("100.10").Percent(21); got != MustParseCastka("21.02") {
+		t.Errorf("21 %% of 100.10 = %s, want 21.02", got)
+	}
+	if got := MustParseCastka("-0.50").Percent(15); got != MustParseCastka("-0.08") {
+		t.Errorf("15 %% of -0.50 = %s, want -0.08", got)
+	}
+}
+
+func TestCastka_Overflow(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() (Castka, error)
+		want Castka
+		err  bool
+	}{
+		{name: "mul", fn: func() (Castka, error) { return CastkaFromKoruny(3).MulChecked(-4) }, want: CastkaFromKoruny(-12)},
+		{name: "mul zero", fn: func() (Castka, error) { return Castka(math.MaxInt64).MulChecked(0) }},
+		{name: "mul overflow", fn: func() (Castka, error) { return Castka(math.MaxInt64 / 2).MulChecked(3) }, err: true},
+		{name: "mul min", fn: func() (Castka, error) { return Castka(math.MinInt64).MulChecked(-1) }, err: true},
+		{name: "mul by min", fn: func() (Castka, error) { return Castka(-1).MulChecked(math.MinInt64) }, err: true},
+		{name: "percent", fn: func() (Castka, error) { return MustParseCastka("100.10").PercentChecked(21) }, want: MustParseCastka("21.02")},
+		{name: "percent overflow", fn: func() (Castka, error) { return Castka(math.MaxInt64 / 10).PercentChecked(21) }, err: true},
+		{name: "percent rounding overflow", fn: func() (Castka, error) { return Castka(math.MaxInt64 - 10).PercentChecked(1) }, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fn()
+			if tt.err {
+				if !errors.Is(err, ErrCastkaOverflow) {
+					t.Errorf("expected ErrCastkaOverflow, got %s, %v", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
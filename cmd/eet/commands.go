@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prochac/eet"
+)
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("eet "+name, flag.ContinueOnError)
+}
+
+func runSend(args []string, out io.Writer) error {
+	return send(args, out, false)
+}
+
+func runVerify(args []string, out io.Writer) error {
+	return send(args, out, true)
+}
+
+func send(args []string, out io.Writer, overeni bool) error {
+	name := "send"
+	if overeni {
+		name = "verify"
+	}
+	fs := newFlagSet(name)
+	df := addDispatcherFlags(fs)
+	rf := addReceiptFlags(fs)
+	var queue *string
+	if !overeni {
+		queue = fs.String("queue", "", "directory of the offline queue the receipt is stored to when not confirmed")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r, err := rf.receipt(os.Stdin)
+	if err != nil {
+		return err
+	}
+	r.Overeni = overeni
+	d, err := df.dispatcher()
+	if err != nil {
+		return err
+	}
+
+	outcome := d.Submit(context.Background(), r)
+	printOutcome(out, outcome)
+	if queue != nil && *queue != "" && outcome.Offline() {
+		store, err := eet.NewFileStore(*queue)
+		if err != nil {
+			return err
+		}
+		resender, err := eet.NewResender(d, store)
+		if err != nil {
+			return err
+		}
+		if err := resender.Enqueue(outcome); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "queued: %s\n", *queue)
+	}
+	return outcome.Err
+}
+
+func printOutcome(out io.Writer, o eet.Outcome) {
+	fmt.Fprintf(out, "uuid_zpravy: %s\n", o.Trzba.Hlavicka.UuidZpravy)
+	if o.Response != nil {
+		if o.Response.Fik != "" {
+			fmt.Fprintf(out, "fik: %s\n", o.Response.Fik)
+		}
+		if o.Response.Overeni {
+			fmt.Fprintln(out, "overeni: ok")
+		}
+		if o.Response.Test {
+			fmt.Fprintln(out, "test: true")
+		}
+		for _, w := range o.Response.Warnings() {
+			fmt.Fprintf(out, "warning: %s\n", w)
+		}
+	}
+	if o.Bkp() != "" {
+		fmt.Fprintf(out, "bkp: %s\n", o.Bkp())
+	}
+	if o.Offline() {
+		fmt.Fprintf(out, "pkp: %s\n", o.Pkp())
+		fmt.Fprintln(out, "offline: true")
+	}
+}
+
+func runPkp(args []string, out io.Writer) error {
+	fs := newFlagSet("pkp")
+	sf := addSignerFlags(fs)
+	rf := addReceiptFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	trzba, err := signedTrzba(sf, rf)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, trzba.KontrolniKody.Pkp.Value)
+	return nil
+}
+
+func runBkp(args []string, out io.Writer) error {
+	fs := newFlagSet("bkp")
+	pkp := fs.String("pkp", "", "base64 encoded PKP, the receipt is signed by -cert otherwise")
+	sf := addSignerFlags(fs)
+	rf := addReceiptFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *pkp != "" {
+		bkp, err := eet.NewBkp(eet.Pkp{Value: *pkp})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, bkp.Value)
+		return nil
+	}
+	trzba, err := signedTrzba(sf, rf)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, trzba.KontrolniKody.Bkp.Value)
+	return nil
+}
+
+func signedTrzba(sf *signerFlags, rf *receiptFlags) (eet.Trzba, error) {
+	signer, err := sf.signer()
+	if err != nil {
+		return eet.Trzba{}, err
+	}
+	r, err := rf.receipt(os.Stdin)
+	if err != nil {
+		return eet.Trzba{}, err
+	}
+	return r.Trzba(signer)
+}
+
+func runCert(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "info" {
+		return fmt.Errorf("usage: eet cert info [-password password] <file.p12>")
+	}
+	fs := newFlagSet("cert info")
+	password := fs.String("password", os.Getenv("EET_PASSWORD"), "password of the .p12 file, defaults to $EET_PASSWORD")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: eet cert info [-password password] <file.p12>")
+	}
+
+	signer, err := eet.NewSigner(fs.Arg(0), *password)
+	if err != nil {
+		return err
+	}
+	cert := signer.Certificate()
+	fmt.Fprintf(out, "subject: %s\n", signer.Subject())
+	fmt.Fprintf(out, "issuer: %s\n", signer.Issuer())
+	if dic, err := signer.DIC(); err == nil {
+		fmt.Fprintf(out, "dic: %s\n", dic)
+	}
+	fmt.Fprintf(out, "serial: %s\n", cert.SerialNumber)
+	fmt.Fprintf(out, "not_before: %s\n", signer.NotBefore().Format(time.RFC3339))
+	fmt.Fprintf(out, "not_after: %s\n", signer.NotAfter().Format(time.RFC3339))
+	fingerprint := sha256.Sum256(cert.Raw)
+	fmt.Fprintf(out, "sha256: %s\n", strings.ToUpper(hex.EncodeToString(fingerprint[:])))
+	for _, c := range signer.Chain() {
+		fmt.Fprintf(out, "chain: %s\n", c.Subject)
+	}
+	if err := signer.CheckValidity(time.Now()); err != nil {
+		fmt.Fprintf(out, "status: %s\n", err)
+	} else {
+		fmt.Fprintf(out, "status: valid, expires in %d days\n", int(time.Until(signer.NotAfter()).Hours()/24))
+	}
+	return nil
+}
+
+func runResend(args []string, out io.Writer) error {
+	fs := newFlagSet("resend")
+	df := addDispatcherFlags(fs)
+	queue := fs.String("queue", "", "directory of the offline queue")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *queue == "" {
+		return fmt.Errorf("-queue is required")
+	}
+
+	d, err := df.dispatcher()
+	if err != nil {
+		return err
+	}
+	store, err := eet.NewFileStore(*queue)
+	if err != nil {
+		return err
+	}
+	items, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, p := range items {
+		outcome := d.Resend(context.Background(), p.Trzba)
+		printOutcome(out, outcome)
+		if outcome.Confirmed() {
+			if err := store.Delete(p.Trzba.Hlavicka.UuidZpravy); err != nil {
+				return err
+			}
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "error: %s\n", outcome.Err)
+		if time.Now().After(p.Deadline()) {
+			fmt.Fprintf(out, "deadline: missed %s\n", p.Deadline().Format(time.RFC3339))
+		}
+		p.Attempts++
+		p.LastError = outcome.Err.Error()
+		if err := store.Save(p); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "confirmed %d of %d\n", len(items)-failed, len(items))
+	if failed > 0 {
+		return fmt.Errorf("%d receipts not confirmed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prochac/eet"
+)
+
+type receiptField struct {
+	name  string
+	usage string
+	set   func(r *eet.Receipt, value string) error
+}
+
+func castkaField(name, attr string, field func(r *eet.Receipt) *eet.Castka) receiptField {
+	return receiptField{
+		name:  name,
+		usage: attr + " amount, e.g. 123.50",
+		set: func(r *eet.Receipt, value string) error {
+			c, err := eet.ParseCastka(value)
+			if err != nil {
+				return err
+			}
+			*field(r) = c
+			return nil
+		},
+	}
+}
+
+// receiptFields are the flags overriding fields of the receipt.
+var receiptFields = []receiptField{
+	{"uuid", "uuid_zpravy, generated when empty", func(r *eet.Receipt, v string) error { r.UuidZpravy = v; return nil }},
+	{"dic", "dic_popl, DIC of the taxpayer", func(r *eet.Receipt, v string) error { r.DicPopl = v; return nil }},
+	{"dic-poverujiciho", "dic_poverujiciho, DIC of the appointing taxpayer", func(r *eet.Receipt, v string) error { r.DicPoverujiciho = v; return nil }},
+	{"provoz", "id_provoz, establishment ID", func(r *eet.Receipt, v string) (err error) { r.IdProvoz, err = strconv.Atoi(v); return err }},
+	{"pokl", "id_pokl, cash register ID", func(r *eet.Receipt, v string) error { r.IdPokl = v; return nil }},
+	{"porad", "porad_cis, receipt serial number", func(r *eet.Receipt, v string) error { r.PoradCis = v; return nil }},
+	{"dat-trzby", "dat_trzby in RFC 3339, now when empty", func(r *eet.Receipt, v string) (err error) {
+		r.DatTrzby, err = time.Parse(time.RFC3339, v)
+		return err
+	}},
+	castkaField("celk", "celk_trzba", func(r *eet.Receipt) *eet.Castka { return &r.CelkTrzba }),
+	castkaField("zakl-nepodl-dph", "zakl_nepodl_dph", func(r *eet.Receipt) *eet.Castka { return &r.ZaklNepodlDph }),
+	castkaField("zakl-dan1", "zakl_dan1", func(r *eet.Receipt) *eet.Castka { return &r.ZaklDan1 }),
+	castkaField("dan1", "dan1", func(r *eet.Receipt) *eet.Castka { return &r.Dan1 }),
+	castkaField("zakl-dan2", "zakl_dan2", func(r *eet.Receipt) *eet.Castka { return &r.ZaklDan2 }),
+	castkaField("dan2", "dan2", func(r *eet.Receipt) *eet.Castka { return &r.Dan2 }),
+	castkaField("zakl-dan3", "zakl_dan3", func(r *eet.Receipt) *eet.Castka { return &r.ZaklDan3 }),
+	castkaField("dan3", "dan3", func(r *eet.Receipt) *eet.Castka { return &r.Dan3 }),
+	castkaField("cest-sluz", "cest_sluz", func(r *eet.Receipt) *eet.Castka { return &r.CestSluz }),
+	castkaField("pouzit-zboz1", "pouzit_zboz1", func(r *eet.Receipt) *eet.Castka { return &r.PouzitZboz1 }),
+	castkaField("pouzit-zboz2", "pouzit_zboz2", func(r *eet.Receipt) *eet.Castka { return &r.PouzitZboz2 }),
+	castkaField("pouzit-zboz3", "pouzit_zboz3", func(r *eet.Receipt) *eet.Castka { return &r.PouzitZboz3 }),
+	castkaField("urceno-cerp-zuct", "urceno_cerp_zuct", func(r *eet.Receipt) *eet.Castka { return &r.UrcenoCerpZuct }),
+	castkaField("cerp-zuct", "cerp_zuct", func(r *eet.Receipt) *eet.Castka { return &r.CerpZuct }),
+	{"rezim", "rezim, 0 regular or 1 simplified", func(r *eet.Receipt, v string) error {
+		rezim, err := strconv.Atoi(v)
+		if err != nil || rezim < 0 || rezim > 1 {
+			return fmt.Errorf("invalid rezim %q", v)
+		}
+		r.Rezim = eet.Regime(rezim)
+		return nil
+	}},
+}
+
+// receiptFlags reads a receipt from a JSON file overridden by flags.
+type receiptFlags struct {
+	fs       *flag.FlagSet
+	file     *string
+	repeated *bool
+}
+
+func addReceiptFlags(fs *flag.FlagSet) *receiptFlags {
+	rf := receiptFlags{
+		fs:       fs,
+		file:     fs.String("receipt", "", "JSON encoded receipt, - for the standard input"),
+		repeated: fs.Bool("repeated", false, "the receipt was sent before, prvni_zaslani is false"),
+	}
+	for _, f := range receiptFields {
+		fs.String(f.name, "", f.usage)
+	}
+	return &rf
+}
+
+// receipt returns the receipt after the flags were parsed.
+func (rf *receiptFlags) receipt(stdin io.Reader) (eet.Receipt, error) {
+	var r eet.Receipt
+	if *rf.file != "" {
+		var data []byte
+		var err error
+		if *rf.file == "-" {
+			data, err = ioutil.ReadAll(stdin)
+		} else {
+			data, err = ioutil.ReadFile(*rf.file)
+		}
+		if err != nil {
+			return eet.Receipt{}, fmt.Errorf("reading receipt: %w", err)
+		}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return eet.Receipt{}, fmt.Errorf("decoding receipt: %w", err)
+		}
+	}
+
+	var err error
+	rf.fs.Visit(func(f *flag.Flag) {
+		for _, field := range receiptFields {
+			if field.name == f.Name && err == nil {
+				if setErr := field.set(&r, f.Value.String()); setErr != nil {
+					err = fmt.Errorf("invalid -%s: %w", f.Name, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return eet.Receipt{}, err
+	}
+
+	if r.DatTrzby.IsZero() {
+		r.DatTrzby = time.Now()
+	}
+	r.PrvniZaslani = !*rf.repeated
+	return r, nil
+}
+
+// signerFlags loads the signer from a .p12 file.
+type signerFlags struct {
+	cert     *string
+	password *string
+}
+
+func addSignerFlags(fs *flag.FlagSet) *signerFlags {
+	return &signerFlags{
+		cert:     fs.String("cert", "", "PKCS#12 (.p12) file with the certificate and private key"),
+		password: fs.String("password", os.Getenv("EET_PASSWORD"), "password of the .p12 file, defaults to $EET_PASSWORD"),
+	}
+}
+
+// loadSigner loads the .p12 file given by -cert, replaced in tests.
+var loadSigner = eet.NewSigner
+
+func (sf *signerFlags) signer() (*eet.Signer, error) {
+	if *sf.cert == "" {
+		return nil, fmt.Errorf("-cert is required")
+	}
+	return loadSigner(*sf.cert, *sf.password)
+}
+
+// dispatcherFlags configure the Dispatcher.
+type dispatcherFlags struct {
+	signer  *signerFlags
+	service *string
+	roots   *string
+	timeout *time.Duration
+}
+
+func addDispatcherFlags(fs *flag.FlagSet) *dispatcherFlags {
+	return &dispatcherFlags{
+		signer:  addSignerFlags(fs),
+		service: fs.String("service", "playground", "playground, production or the service URL"),
+		roots:   fs.String("roots", "", "comma separated CA certificates of the response signature, required"),
+		timeout: fs.Duration("timeout", eet.DefaultTimeout, "timeout of the request"),
+	}
+}
+
+func (df *dispatcherFlags) dispatcher() (*eet.Dispatcher, error) {
+	signer, err := df.signer.signer()
+	if err != nil {
+		return nil, err
+	}
+	service := eet.Service(*df.service)
+	switch *df.service {
+	case "playground":
+		service = eet.PlaygroundService
+	case "production":
+		service = eet.ProductionService
+	}
+	if *df.roots == "" {
+		return nil, fmt.Errorf("-roots is required")
+	}
+	roots, err := eet.LoadCertPool(strings.Split(*df.roots, ",")...)
+	if err != nil {
+		return nil, err
+	}
+	return eet.NewDispatcher(service, signer,
+		eet.WithTimeout(*df.timeout), eet.WithUserAgent("eet-cli"), eet.WithResponseRoots(roots))
+}
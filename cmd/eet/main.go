@@ -0,0 +1,72 @@
+// Command eet sends and inspects EET receipts.
+//
+// Usage:
+//
+//	eet send -cert CZ00000019.p12 -password eet -roots ca.crt -receipt receipt.json
+//	eet verify -cert CZ00000019.p12 -password eet -roots ca.crt -dic CZ00000019 -provoz 273 -pokl 1 -porad 1 -celk 100
+//	eet pkp -cert CZ00000019.p12 -password eet -receipt receipt.json
+//	eet bkp -pkp <base64 PKP>
+//	eet cert info -password eet CZ00000019.p12
+//	eet resend -cert CZ00000019.p12 -password eet -roots ca.crt -queue /var/lib/eet/queue
+//
+// A receipt is read from a JSON encoded eet.Receipt given by -receipt, "-"
+// for the standard input, and fields given by flags override it. Run
+// "eet <command> -h" for the flags of a command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	usage string
+	run   func(args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"send":   {"send a receipt and print FIK, or PKP and BKP when offline", runSend},
+	"verify": {"send a receipt in verification mode (overeni)", runVerify},
+	"pkp":    {"compute PKP of a receipt offline", runPkp},
+	"bkp":    {"compute BKP of a receipt or of a PKP offline", runBkp},
+	"cert":   {"inspect a .p12 certificate: cert info <file>", runCert},
+	"resend": {"resend all receipts of an offline queue", runResend},
+}
+
+var commandOrder = []string{"send", "verify", "pkp", "bkp", "cert", "resend"}
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "eet:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		usage(out)
+		return flag.ErrHelp
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(out)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd.run(args[1:], out)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: eet <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].usage)
+	}
+}
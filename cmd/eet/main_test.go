@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prochac/eet"
+	"github.com/prochac/eet/eettest"
+)
+
+const testCert = "../../testdata/keystore/CZ00000019.p12"
+
+func TestRun_ControlCodes(t *testing.T) {
+	receipt := []string{
+		"-cert", testCert, "-password", "eet",
+		"-dic", "CZ00000019", "-provoz", "273", "-pokl", "/5546/RO24", "-porad", "0/6460/ZQ42",
+		"-dat-trzby", "2019-01-01T10:00:00+01:00", "-celk", "34113.00",
+	}
+
+	var pkp bytes.Buffer
+	if err := run(append([]string{"pkp"}, receipt...), &pkp); err != nil {
+		t.Fatal(err)
+	}
+	var bkp bytes.Buffer
+	if err := run(append([]string{"bkp"}, receipt...), &bkp); err != nil {
+		t.Fatal(err)
+	}
+	var bkpFromPkp bytes.Buffer
+	if err := run([]string{"bkp", "-pkp", strings.TrimSpace(pkp.String())}, &bkpFromPkp); err != nil {
+		t.Fatal(err)
+	}
+	if bkp.String() != bkpFromPkp.String() || len(strings.TrimSpace(bkp.String())) != 44 {
+		t.Errorf("BKP %q differs from BKP of PKP %q", bkp.String(), bkpFromPkp.String())
+	}
+
+	if err := run([]string{"pkp", "-cert", testCert, "-password", "eet", "-celk", "1.005"}, &pkp); err == nil {
+		t.Error("expected error for invalid amount")
+	}
+}
+
+func TestRun_CertInfo(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"cert", "info", "-password", "eet", testCert}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"dic: CZ00000019", "issuer: CN=EET CA Test", "status: valid"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output misses %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	var out bytes.Buffer
+	if err := run(nil, &out); err == nil {
+		t.Error("expected error without command")
+	}
+	if err := run([]string{"sign"}, &out); err == nil {
+		t.Error("expected error for unknown command")
+	}
+	if !strings.Contains(out.String(), "Usage: eet <command>") {
+		t.Errorf("usage not written to out:\n%s", out.String())
+	}
+}
+
+// startServer starts an eettest.Server the commands are signed for, it
+// returns the flags connecting to it and a function shutting it down.
+func startServer(t *testing.T) (*eettest.Server, []string, func()) {
+	t.Helper()
+	srv, err := eettest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "eet-cli")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	roots := filepath.Join(dir, "roots.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.CA.Certificate().Raw})
+	if err := ioutil.WriteFile(roots, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := srv.CA.NewSigner("CZ00000019")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadSigner = func(file, password string) (*eet.Signer, error) {
+		return signer, nil
+	}
+	flags := []string{"-cert", "CZ00000019.p12", "-service", srv.URL, "-roots", roots}
+	return srv, flags, func() {
+		loadSigner = eet.NewSigner
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+var testReceipt = []string{"-dic", "CZ00000019", "-provoz", "273", "-pokl", "/5546/RO24", "-porad", "0/6460/ZQ42", "-celk", "100.00"}
+
+func TestRun_Send(t *testing.T) {
+	srv, flags, stop := startServer(t)
+	defer stop()
+
+	var out bytes.Buffer
+	if err := run(append([]string{"send", "-cert", "CZ00000019.p12", "-service", srv.URL}, testReceipt...), &out); err == nil || !strings.Contains(err.Error(), "-roots") {
+		t.Errorf("expected error without -roots, got %v", err)
+	}
+	if err := run(append(append([]string{"send"}, flags...), testReceipt...), &out); err != nil {
+		t.Fatal(err)
+	}
+	requests := srv.Requests()
+	if len(requests) != 1 || requests[0].Fik == "" {
+		t.Fatalf("expected a confirmed request, got %v", requests)
+	}
+	for _, want := range []string{"fik: " + requests[0].Fik, "bkp: ", "test: true"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output misses %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := run(append(append([]string{"verify"}, flags...), testReceipt...), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "overeni: ok") {
+		t.Errorf("output misses overeni:\n%s", out.String())
+	}
+	if requests := srv.Requests(); len(requests) != 2 || !requests[1].Trzba.Hlavicka.Overeni {
+		t.Errorf("expected a message in verification mode, got %v", requests)
+	}
+}
+
+func TestRun_SendRejected(t *testing.T) {
+	srv, flags, stop := startServer(t)
+	defer stop()
+	queue, err := ioutil.TempDir("", "eet-queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(queue)
+
+	srv.Enqueue(eettest.Reply{Code: eet.CodeSchemaViolation})
+	var out bytes.Buffer
+	args := append(append([]string{"send", "-queue", queue}, flags...), testReceipt...)
+	err = run(args, &out)
+	var chyba *eet.Chyba
+	if !errors.As(err, &chyba) || chyba.Kod != eet.CodeSchemaViolation {
+		t.Fatalf("expected Chyba %d, got %v", eet.CodeSchemaViolation, err)
+	}
+	if strings.Contains(out.String(), "queued") || strings.Contains(out.String(), "offline") {
+		t.Errorf("rejected receipt queued:\n%s", out.String())
+	}
+	if files, _ := ioutil.ReadDir(queue); len(files) != 0 {
+		t.Errorf("expected empty queue, got %d files", len(files))
+	}
+}
+
+func TestRun_Resend(t *testing.T) {
+	srv, flags, stop := startServer(t)
+	defer stop()
+	queue, err := ioutil.TempDir("", "eet-queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(queue)
+
+	// the receipt is queued when the server is unavailable
+	srv.Enqueue(eettest.Reply{Status: 503})
+	var out bytes.Buffer
+	if err := run(append(append([]string{"send", "-queue", queue}, flags...), testReceipt...), &out); err == nil {
+		t.Fatal("expected error for unavailable server")
+	}
+	for _, want := range []string{"pkp: ", "offline: true", "queued: " + queue} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output misses %q:\n%s", want, out.String())
+		}
+	}
+
+	// a failed resend keeps it queued
+	srv.Enqueue(eettest.Reply{Status: 503})
+	out.Reset()
+	if err := run(append([]string{"resend", "-queue", queue}, flags...), &out); err == nil {
+		t.Error("expected error for unavailable server")
+	}
+	if !strings.Contains(out.String(), "confirmed 0 of 1") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := run(append([]string{"resend", "-queue", queue}, flags...), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "fik: ") || !strings.Contains(out.String(), "confirmed 1 of 1") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	requests := srv.Requests()
+	if len(requests) != 3 || requests[2].Trzba.Hlavicka.PrvniZaslani {
+		t.Errorf("expected the receipt resent as a repeated message, got %d requests", len(requests))
+	}
+	if files, _ := ioutil.ReadDir(queue); len(files) != 0 {
+		t.Errorf("expected empty queue, got %d files", len(files))
+	}
+
+	if err := run([]string{"resend"}, &out); err == nil {
+		t.Error("expected error without -queue")
+	}
+}
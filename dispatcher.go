@@ -2,12 +2,18 @@ package eet
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
-
-	"github.com/pkg/errors"
 )
 
 type Service string
@@ -17,6 +23,16 @@ const (
 	ProductionService Service = "https://prod.eet.cz:443/eet/services/EETServiceSOAP/v3"
 )
 
+// productionHost is the host of ProductionService.
+const productionHost = "prod.eet.cz"
+
+// production reports whether the service is ProductionService, whatever
+// the port or the path of its URL.
+func (s Service) production() bool {
+	u, err := url.Parse(string(s))
+	return err == nil && strings.EqualFold(strings.TrimSuffix(u.Hostname(), "."), productionHost)
+}
+
 type Regime int
 
 const (
@@ -25,70 +41,455 @@ const (
 )
 
 type Dispatcher struct {
-	service     Service
-	signer      *Signer
-	certificate *x509.Certificate
-	testing     bool
-}
+	service  Service
+	signer   *Signer
+	keystore Keystore
+	roots    *x509.CertPool
 
-func NewDispatcher(service Service, certPath, password string) (*Dispatcher, error) {
-	signer, err := NewSigner(certPath, password)
-	if err != nil {
-		return nil, errors.Wrap(err, "Failed to create signer")
-	}
+	client    *http.Client
+	timeout   *time.Duration
+	tlsConfig *tls.Config
+	userAgent string
+
+	onWarnings  func(Trzba, []Warning)
+	expiry      *expiryWarning
+	retryPolicy RetryPolicy
+	sequencer   Sequencer
+	observer    Observer
+	metrics     Metrics
 
+	inFlight inFlight
+}
+
+// NewDispatcher creates a Dispatcher sending receipts signed by signer to service.
+// A single HTTP client is created, or taken from WithHTTPClient, and reused by all calls.
+// signer may be nil when signers are provided by WithKeystore.
+func NewDispatcher(service Service, signer *Signer, opts ...Option) (*Dispatcher, error) {
 	d := Dispatcher{
 		service: service,
 		signer:  signer,
 	}
+	for _, opt := range opts {
+		opt(&d)
+	}
+	if d.signer == nil && d.keystore == nil {
+		return nil, errors.New("signer or keystore is required")
+	}
+	if d.roots == nil {
+		return nil, errors.New("response roots are required, see WithResponseRoots")
+	}
+
+	client, err := d.httpClient()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create HTTP client: %w", err)
+	}
+	d.client = client
 
 	return &d, nil
 }
 
+// maxIdleConnsPerHost is the number of keep-alive connections kept by the
+// default transport, so concurrent requests of SendBatch reuse them.
+const maxIdleConnsPerHost = 64
+
+// httpClient builds the client from the configured options. A client passed
+// by WithHTTPClient is copied, so the caller's instance is never modified.
+func (d *Dispatcher) httpClient() (*http.Client, error) {
+	var client http.Client
+	if d.client != nil {
+		client = *d.client
+	} else {
+		client.Timeout = DefaultTimeout
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		client.Transport = transport
+	}
+	if d.timeout != nil {
+		client.Timeout = *d.timeout
+	}
+	if d.tlsConfig != nil {
+		var transport *http.Transport
+		switch t := client.Transport.(type) {
+		case nil:
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			transport = t.Clone()
+		default:
+			return nil, fmt.Errorf("TLS config cannot be applied to transport %T", t)
+		}
+		transport.TLSClientConfig = d.tlsConfig
+		client.Transport = transport
+	}
+	return &client, nil
+}
+
+// TimeoutError is returned when no response arrived in time, either because
+// the context deadline expired or the HTTP client timed out. The receipt
+// should then be issued in offline mode.
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return "eet: no response in time: " + e.Err.Error()
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports true, so TimeoutError satisfies net.Error-like checks.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// HTTPError is returned when the EET server responds with other HTTP status than 200 OK,
+// e.g. with a SOAP fault or when the service is unavailable.
+type HTTPError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("eet: unexpected HTTP status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// SendPayment sends the receipt and returns the confirmed response.
+// A response without a valid signature results in a *SignatureError.
 func (d *Dispatcher) SendPayment(receipt Receipt) (*Response, error) {
-	trzba, err := receipt.Trzba(d.signer)
+	return d.SendPaymentContext(context.Background(), receipt)
+}
+
+// SendPaymentContext is like SendPayment but honours the deadline and
+// cancellation of ctx while signing, sending and decoding. When the deadline
+// expires before the response is received, a *TimeoutError is returned.
+// The law requires a response within 2 seconds, e.g.
+//
+//	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+//	defer cancel()
+//	response, err := d.SendPaymentContext(ctx, receipt)
+func (d *Dispatcher) SendPaymentContext(ctx context.Context, receipt Receipt) (*Response, error) {
+	outcome := d.Submit(ctx, receipt)
+	return outcome.Response, outcome.Err
+}
+
+// VerifyPayment sends the receipt in verification mode (overeni). The server
+// checks the message without registering it, a nil error means the receipt
+// would be accepted. The returned Response has Overeni set and no Fik.
+func (d *Dispatcher) VerifyPayment(ctx context.Context, receipt Receipt) (*Response, error) {
+	receipt.Overeni = true
+	return d.SendPaymentContext(ctx, receipt)
+}
+
+// Submit sends the receipt like SendPaymentContext, but the returned Outcome
+// carries the signed Trzba with its control codes even when sending failed,
+// so the receipt can be issued in offline mode and resent later.
+// Empty UuidZpravy is generated, empty PoradCis is allocated by the Sequencer
+// set by WithSequencer.
+func (d *Dispatcher) Submit(ctx context.Context, receipt Receipt) Outcome {
+	return d.measure(d.submit(ctx, receipt))
+}
+
+func (d *Dispatcher) submit(ctx context.Context, receipt Receipt) Outcome {
+	if err := ctx.Err(); err != nil {
+		return Outcome{Err: contextError(err)}
+	}
+	if receipt.UuidZpravy == "" {
+		var err error
+		if receipt.UuidZpravy, err = newUUID(); err != nil {
+			return Outcome{Err: err}
+		}
+	}
+	if err := d.inFlight.acquire(UUIDType(receipt.UuidZpravy)); err != nil {
+		return Outcome{Err: err}
+	}
+	defer d.inFlight.release(UUIDType(receipt.UuidZpravy))
+	signer, err := d.signerFor(CZDICType(receipt.DicPopl), IdProvozType(receipt.IdProvoz))
+	if err != nil {
+		return Outcome{Err: err}
+	}
+	if err := d.checkSigner(signer, CZDICType(receipt.DicPopl), CZDICType(receipt.DicPoverujiciho)); err != nil {
+		return Outcome{Err: err}
+	}
+	if d.sequencer != nil && receipt.PoradCis == "" {
+		if receipt.PoradCis, err = d.nextPoradCis(receipt); err != nil {
+			return Outcome{Err: err}
+		}
+	}
+
+	trzba, err := receipt.Trzba(signer)
+	if err != nil {
+		return Outcome{Err: fmt.Errorf("Failed to convert Receipt to Trzba: %w", err)}
+	}
+
+	trzba, response, err := d.sendRetrying(ctx, trzba, signer)
+	return Outcome{Trzba: trzba, Response: response, Err: err}
+}
+
+// nextPoradCis allocates porad_cis of receipt from the sequencer. The receipt
+// is checked first, so no number is wasted on a receipt which cannot be sent.
+func (d *Dispatcher) nextPoradCis(receipt Receipt) (string, error) {
+	receipt.PoradCis = "0"
+	if _, err := receipt.trzba(); err != nil {
+		return "", fmt.Errorf("Failed to convert Receipt to Trzba: %w", err)
+	}
+	poradCis, err := d.sequencer.Next(CZDICType(receipt.DicPopl), IdProvozType(receipt.IdProvoz), receipt.IdPokl)
+	if err != nil {
+		return "", fmt.Errorf("Failed to allocate porad_cis: %w", err)
+	}
+	return poradCis, nil
+}
+
+// Resend sends a Trzba that was not confirmed before, e.g. one stored from
+// an offline Outcome. It is sent with PrvniZaslani false and a fresh
+// DatOdesl, keeping UuidZpravy, PKP and BKP of the original.
+func (d *Dispatcher) Resend(ctx context.Context, trzba Trzba) Outcome {
+	return d.measure(d.resend(ctx, trzba))
+}
+
+func (d *Dispatcher) resend(ctx context.Context, trzba Trzba) Outcome {
+	trzba.Hlavicka.PrvniZaslani = false
+	trzba.Hlavicka.DatOdesl = NewDateTimeType(time.Now())
+	if err := d.inFlight.acquire(trzba.Hlavicka.UuidZpravy); err != nil {
+		return Outcome{Trzba: trzba, Err: err}
+	}
+	defer d.inFlight.release(trzba.Hlavicka.UuidZpravy)
+	signer, err := d.signerFor(trzba.Data.DicPopl, trzba.Data.IdProvoz)
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to convert Receipt to Trzba")
+		return Outcome{Trzba: trzba, Err: err}
+	}
+	if err := d.checkSigner(signer, trzba.Data.DicPopl, trzba.Data.DicPoverujiciho); err != nil {
+		return Outcome{Trzba: trzba, Err: err}
+	}
+
+	trzba, response, err := d.sendRetrying(ctx, trzba, signer)
+	return Outcome{Trzba: trzba, Response: response, Err: err}
+}
+
+// measure reports the outcome of a receipt to the metrics.
+func (d *Dispatcher) measure(o Outcome) Outcome {
+	if d.metrics != nil {
+		d.metrics.ObserveOutcome(o)
+	}
+	return o
+}
+
+// CertificateMismatchError is returned before sending when the DIC in the
+// signing certificate is neither dic_popl nor dic_poverujiciho of the receipt.
+// The server would accept such a message only with a warning.
+type CertificateMismatchError struct {
+	CertificateDIC  CZDICType
+	DicPopl         CZDICType
+	DicPoverujiciho CZDICType
+}
+
+func (e *CertificateMismatchError) Error() string {
+	if e.DicPoverujiciho != "" {
+		return fmt.Sprintf("eet: certificate DIC %s matches neither dic_popl %s nor dic_poverujiciho %s", e.CertificateDIC, e.DicPopl, e.DicPoverujiciho)
+	}
+	return fmt.Sprintf("eet: certificate DIC %s does not match dic_popl %s", e.CertificateDIC, e.DicPopl)
+}
+
+// checkSigner checks that the certificate of signer was issued to the taxpayer
+// of the receipt and calls the expiry warning hook.
+func (d *Dispatcher) checkSigner(signer *Signer, dicPopl, dicPoverujiciho CZDICType) error {
+	if d.expiry != nil {
+		d.expiry.check(signer.Certificate(), time.Now())
 	}
 
-	envelope, err := NewSOAPEnvelopeRequest(trzba, d.signer)
+	dic, err := signer.DIC()
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to create SOAPEnvelopeRequest")
+		return err
+	}
+	if dic != dicPopl && (dicPoverujiciho == "" || dic != dicPoverujiciho) {
+		return &CertificateMismatchError{CertificateDIC: dic, DicPopl: dicPopl, DicPoverujiciho: dicPoverujiciho}
+	}
+	return nil
+}
+
+// send signs the envelope with trzba by signer, sends it and decodes the verified response.
+// The attempt is reported to the observer.
+func (d *Dispatcher) send(ctx context.Context, trzba Trzba, signer *Signer, attempt int) (*Response, error) {
+	ex := Exchange{Trzba: trzba, Attempt: attempt, Start: time.Now()}
+	response, err := d.exchange(ctx, trzba, signer, &ex)
+	ex.Duration = time.Since(ex.Start)
+	ex.Response, ex.Err = response, err
+	if d.observer != nil {
+		d.observer.Observe(ex)
+	}
+	if d.metrics != nil {
+		d.metrics.ObserveExchange(ex)
+	}
+	return response, err
+}
+
+// exchange does the work of send, recording the messages, status and timings in ex.
+func (d *Dispatcher) exchange(ctx context.Context, trzba Trzba, signer *Signer, ex *Exchange) (*Response, error) {
+	mark := ex.Start
+	lap := func() time.Duration {
+		now := time.Now()
+		elapsed := now.Sub(mark)
+		mark = now
+		return elapsed
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	if err := ValidateTrzba(trzba); err != nil {
+		return nil, err
+	}
+
+	envelope, err := NewSOAPEnvelopeRequest(trzba, signer)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create SOAPEnvelopeRequest: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
 	}
 
 	var buf bytes.Buffer
 	buf.WriteString(xml.Header)
 	if err := xml.NewEncoder(&buf).Encode(envelope); err != nil {
-		return nil, errors.Wrap(err, "Failed to marshal SOAPEnvelopeRequest")
+		return nil, fmt.Errorf("Failed to marshal SOAPEnvelopeRequest: %w", err)
+	}
+
+	ex.RequestBody = buf.Bytes()
+	ex.Signing = lap()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, string(d.service), bytes.NewReader(ex.RequestBody))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	if d.userAgent != "" {
+		req.Header.Set("User-Agent", d.userAgent)
 	}
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Post(string(d.service), "application/xml", &buf)
+	resp, err := d.client.Do(req)
 	if resp != nil {
 		defer func() {
 			_ = resp.Body.Close()
 		}()
 	}
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to send payment")
+		ex.Network = lap()
+		return nil, transportError(ctx, err, "Failed to send payment")
+	}
+	ex.StatusCode = resp.StatusCode
+
+	resBody, err := ioutil.ReadAll(resp.Body)
+	ex.ResponseBody = resBody
+	ex.Network = lap()
+	if err != nil {
+		return nil, transportError(ctx, err, "Failed to read response")
+	}
+	defer func() {
+		ex.Decoding = lap()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: resBody}
+	}
+	_, signedBody, err := VerifySignature(resBody, d.roots)
+	if err != nil {
+		return nil, err
 	}
 
+	// only the signed Body is decoded, elements outside of it are not trusted
 	var resEnvelope SOAPEnvelopeResponse
-	if err := xml.NewDecoder(resp.Body).Decode(&resEnvelope); err != nil {
-		return nil, errors.Wrap(err, "Failed to xml.Unmarshal SOAPEnvelopeResponse")
+	if err := xml.Unmarshal(signedBody, &resEnvelope.Body); err != nil {
+		return nil, fmt.Errorf("Failed to xml.Unmarshal SOAPEnvelopeResponse: %w", err)
 	}
 
 	odpoved := resEnvelope.Body.Odpoved
-	if odpoved.Chyba != nil {
-		return nil, odpoved.Chyba
+	if len(odpoved.Varovani) > 0 && d.onWarnings != nil {
+		d.onWarnings(trzba, warnings(odpoved))
 	}
 
 	response := Response{
-		DatPrij: odpoved.Hlavicka.DatPrij,
-		Fik:     odpoved.Potvrzeni.Fik,
-		Bkp:     odpoved.Hlavicka.Bkp,
-		odpoved: odpoved,
+		UuidZpravy: odpoved.Hlavicka.UuidZpravy,
+		DatPrij:    odpoved.Hlavicka.DatPrij,
+		DatOdmit:   odpoved.Hlavicka.DatOdmit,
+		Bkp:        odpoved.Hlavicka.Bkp,
+		odpoved:    odpoved,
+	}
+	switch {
+	case trzba.Hlavicka.Overeni && odpoved.Chyba != nil && odpoved.Chyba.Kod == CodeVerificationOK:
+		// code 0 means the message sent in verification mode would be accepted
+		response.Overeni = true
+		response.Test = odpoved.Chyba.Test
+	case odpoved.Chyba != nil:
+		if err := checkEcho(trzba, odpoved.Hlavicka, false); err != nil {
+			return nil, err
+		}
+		odpoved.Chyba.DatOdmit = odpoved.Hlavicka.DatOdmit
+		return nil, odpoved.Chyba
+	case odpoved.Potvrzeni == nil:
+		return nil, errors.New("Response contains neither Potvrzeni nor Chyba")
+	default:
+		if !fikPattern.MatchString(odpoved.Potvrzeni.Fik) {
+			return nil, patternError("FikType", odpoved.Potvrzeni.Fik, fikPattern)
+		}
+		response.Fik = odpoved.Potvrzeni.Fik
+		response.Test = odpoved.Potvrzeni.Test
+	}
+	if err := checkEcho(trzba, odpoved.Hlavicka, true); err != nil {
+		return nil, err
+	}
+	if response.Test && d.service.production() {
+		return nil, ErrTestResponse
 	}
 
 	return &response, nil
 }
+
+// ErrTestResponse is returned when the production service returns a response
+// flagged as test, its FIK must not be printed on a receipt.
+var ErrTestResponse = errors.New("eet: test response from production service")
+
+// MismatchError is returned when the response does not echo the uuid_zpravy
+// or bkp of the sent message, so it does not belong to the sent receipt.
+type MismatchError struct {
+	Field    string
+	Sent     string
+	Received string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("eet: response %s %q does not match sent %q", e.Field, e.Received, e.Sent)
+}
+
+// checkEcho compares uuid_zpravy and bkp of the response header with the sent trzba.
+// Unless required, values missing in the response are not checked.
+func checkEcho(trzba Trzba, hlavicka OdpovedHlavicka, required bool) error {
+	sentUUID := string(trzba.Hlavicka.UuidZpravy)
+	if (required || hlavicka.UuidZpravy != "") && !strings.EqualFold(hlavicka.UuidZpravy, sentUUID) {
+		return &MismatchError{Field: "uuid_zpravy", Sent: sentUUID, Received: hlavicka.UuidZpravy}
+	}
+	sentBkp := trzba.KontrolniKody.Bkp.Value
+	if (required || hlavicka.Bkp != "") && !strings.EqualFold(hlavicka.Bkp, sentBkp) {
+		return &MismatchError{Field: "bkp", Sent: sentBkp, Received: hlavicka.Bkp}
+	}
+	return nil
+}
+
+// contextError converts an error of an expired context to *TimeoutError.
+func contextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return &TimeoutError{Err: err}
+	}
+	return err
+}
+
+// transportError classifies a failure of the HTTP exchange, timeouts of ctx
+// or of the client are returned as *TimeoutError.
+func transportError(ctx context.Context, err error, message string) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return contextError(ctxErr)
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return &TimeoutError{Err: err}
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
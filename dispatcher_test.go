@@ -1,30 +1,46 @@
-package eet
+package eet_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/prochac/eet"
+	"github.com/prochac/eet/eettest"
 )
 
+// TestDispatcher_SendPayment sends a receipt to the EET playground,
+// it runs only with EET_PLAYGROUND set.
 func TestDispatcher_SendPayment(t *testing.T) {
-	d, err := NewDispatcher(PlaygroundService, "testdata/EET_CA1_Playground-CZ00000019.p12", "eet")
+	if os.Getenv("EET_PLAYGROUND") == "" {
+		t.Skip("set EET_PLAYGROUND to send a receipt to the playground")
+	}
+	signer, err := eet.NewSigner("testdata/EET_CA1_Playground-CZ00000019.p12", "eet")
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	r := Receipt{
-		UuidZpravy: uuid.Must(uuid.NewV4()).String(),
-		DicPopl:    "CZ00000019",
-		IdProvoz:   273,
-		IdPokl:     "/5546/RO24",
-		PoradCis:   "0/6460/ZQ42",
-		DatTrzby:   time.Now(),
-		CelkTrzba:  0,
-		Rezim:      RegularRegime,
+	roots, err := eet.LoadCertPool("testdata/EET_CA1_Playground-ca.crt")
+	if err != nil {
+		t.Fatal(err)
 	}
-	response, err := d.SendPayment(r)
+	d, err := eet.NewDispatcher(eet.PlaygroundService, signer, eet.WithResponseRoots(roots))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := d.SendPayment(newReceipt())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -36,3 +52,641 @@ func TestDispatcher_SendPayment(t *testing.T) {
 	fmt.Println("Fik: ", response.Fik)
 	fmt.Println("Bkp: ", response.Bkp)
 }
+
+func TestNewDispatcher_RequiresRoots(t *testing.T) {
+	ca, err := eettest.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ca.NewSigner("CZ00000019")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eet.NewDispatcher(eet.PlaygroundService, signer); err == nil {
+		t.Error("expected error without response roots")
+	}
+}
+
+func newReceipt() eet.Receipt {
+	return eet.Receipt{
+		UuidZpravy:   uuid.Must(uuid.NewV4()).String(),
+		PrvniZaslani: true,
+		DicPopl:      "CZ00000019",
+		IdProvoz:     273,
+		IdPokl:       "/5546/RO24",
+		PoradCis:     "0/6460/ZQ42",
+		DatTrzby:     time.Now(),
+		CelkTrzba:    0,
+		Rezim:        eet.RegularRegime,
+	}
+}
+
+// newTestDispatcher starts a fake EET service and creates a Dispatcher
+// sending to it with the signer of CZ00000019. The caller closes the server.
+func newTestDispatcher(t *testing.T, opts ...eet.Option) (*eettest.Server, *eet.Dispatcher) {
+	t.Helper()
+	srv, err := eettest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := srv.CA.NewSigner("CZ00000019")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	opts = append([]eet.Option{eet.WithResponseRoots(srv.CA.Pool())}, opts...)
+	d, err := eet.NewDispatcher(srv.Service(), signer, opts...)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return srv, d
+}
+
+func TestDispatcher_SendPaymentMock(t *testing.T) {
+	srv, d := newTestDispatcher(t)
+	defer srv.Close()
+
+	response, err := d.SendPayment(newReceipt())
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := srv.Requests()
+	if len(requests) != 1 || requests[0].Err != nil {
+		t.Fatalf("unexpected requests %+v", requests)
+	}
+	if response.Fik != requests[0].Fik || !response.Test {
+		t.Errorf("unexpected response FIK %s, test %t", response.Fik, response.Test)
+	}
+	if len(response.Warnings()) != 0 {
+		t.Errorf("unexpected warnings %v", response.Warnings())
+	}
+}
+
+func TestDispatcher_VerifyPayment(t *testing.T) {
+	srv, d := newTestDispatcher(t)
+	defer srv.Close()
+
+	response, err := d.VerifyPayment(context.Background(), newReceipt())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.Overeni || response.Fik != "" || !response.Test {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if requests := srv.Requests(); len(requests) != 1 || !requests[0].Trzba.Hlavicka.Overeni {
+		t.Fatalf("expected a message in verification mode, got %v", requests)
+	}
+
+	r := newReceipt()
+	r.Overeni = true
+	outcome := d.Submit(context.Background(), r)
+	if !outcome.Confirmed() || outcome.Offline() || outcome.Rejected() {
+		t.Errorf("expected confirmed verification, got %+v", outcome)
+	}
+
+	// a message rejected in verification mode reports the error
+	srv.Enqueue(eettest.Reply{Code: eet.CodeSchemaViolation})
+	_, err = d.VerifyPayment(context.Background(), newReceipt())
+	var chyba *eet.Chyba
+	if !errors.As(err, &chyba) || chyba.Kod != eet.CodeSchemaViolation {
+		t.Errorf("expected Chyba %d, got %v", eet.CodeSchemaViolation, err)
+	}
+}
+
+func TestDispatcher_CodeVerificationOK(t *testing.T) {
+	srv, d := newTestDispatcher(t)
+	defer srv.Close()
+
+	// code 0 does not confirm a message sent to be registered
+	srv.Enqueue(eettest.Reply{Overeni: true})
+	outcome := d.Submit(context.Background(), newReceipt())
+	var chyba *eet.Chyba
+	if !errors.As(outcome.Err, &chyba) || chyba.Kod != eet.CodeVerificationOK {
+		t.Fatalf("expected Chyba 0, got %v", outcome.Err)
+	}
+	if outcome.Confirmed() || outcome.Response != nil {
+		t.Errorf("code 0 confirmed a message not sent in verification mode: %+v", outcome)
+	}
+}
+
+func TestDispatcher_SendPaymentFaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply eettest.Reply
+		check func(error) bool
+	}{
+		{
+			name:  "chyba",
+			reply: eettest.Reply{Code: eet.CodeSchemaViolation},
+			check: func(err error) bool { return errors.Is(err, eet.ErrSchemaViolation) && !eet.IsRetryable(err) },
+		},
+		{
+			name:  "temporary chyba",
+			reply: eettest.Reply{Code: eet.CodeTemporaryError},
+			check: eet.IsTemporary,
+		},
+		{
+			name:  "service unavailable",
+			reply: eettest.Reply{Status: 503},
+			check: func(err error) bool {
+				var httpErr *eet.HTTPError
+				return errors.As(err, &httpErr) && httpErr.StatusCode == 503
+			},
+		},
+		{
+			name:  "malformed XML",
+			reply: eettest.Reply{Body: []byte(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`)},
+			check: func(err error) bool { return err != nil },
+		},
+		{
+			name:  "unsigned response",
+			reply: eettest.Reply{Unsigned: true},
+			check: func(err error) bool { return errors.Is(err, eet.ErrMissingSignature) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, d := newTestDispatcher(t)
+			defer srv.Close()
+			srv.Enqueue(tt.reply)
+
+			_, err := d.SendPayment(newReceipt())
+			if !tt.check(err) {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestDispatcher_Warnings(t *testing.T) {
+	var calls int
+	var warnedTrzba eet.Trzba
+	var warned []eet.Warning
+	srv, d := newTestDispatcher(t, eet.WithWarningHandler(func(trzba eet.Trzba, warnings []eet.Warning) {
+		calls++
+		warnedTrzba = trzba
+		warned = warnings
+	}))
+	defer srv.Close()
+	srv.Enqueue(eettest.Reply{Warnings: []eet.WarningCode{eet.WarningDatTrzbyFarInPast, eet.WarningDatTrzbyAfterDatPrij}})
+
+	r := newReceipt()
+	response, err := d.SendPayment(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || string(warnedTrzba.Hlavicka.UuidZpravy) != r.UuidZpravy {
+		t.Fatalf("expected the handler called once with the sent Trzba, got %d calls", calls)
+	}
+	codes := []eet.WarningCode{eet.WarningDatTrzbyFarInPast, eet.WarningDatTrzbyAfterDatPrij}
+	if len(warned) != len(codes) {
+		t.Fatalf("unexpected warnings %v", warned)
+	}
+	for i, code := range codes {
+		if warned[i].Code != code || warned[i].Message != code.String() {
+			t.Errorf("warning %d = %v, want code %d", i, warned[i], code)
+		}
+	}
+	if got := response.Warnings(); len(got) != len(warned) || got[0] != warned[0] || got[1] != warned[1] {
+		t.Errorf("response warnings %v differ from handled %v", got, warned)
+	}
+
+	// the handler is not called without warnings
+	response, err = d.SendPayment(newReceipt())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || len(response.Warnings()) != 0 {
+		t.Errorf("unexpected %d calls and warnings %v", calls, response.Warnings())
+	}
+}
+
+func TestDispatcher_SendPaymentContextDeadline(t *testing.T) {
+	srv, d := newTestDispatcher(t, eet.WithTimeout(0))
+	defer srv.Close()
+	srv.Enqueue(eettest.Reply{Delay: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := d.SendPaymentContext(ctx, newReceipt())
+	var timeoutErr *eet.TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected TimeoutError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestDispatcher_SubmitOffline(t *testing.T) {
+	srv, d := newTestDispatcher(t)
+	defer srv.Close()
+	srv.Enqueue(eettest.Reply{Status: 503})
+
+	outcome := d.Submit(context.Background(), newReceipt())
+	if outcome.Err == nil || !outcome.Offline() {
+		t.Fatalf("expected offline outcome, got %+v", outcome)
+	}
+	if len(outcome.Pkp()) == 0 || len(outcome.Bkp()) != 44 {
+		t.Errorf("unexpected control codes PKP %q BKP %q", outcome.Pkp(), outcome.Bkp())
+	}
+
+	resent := d.Resend(context.Background(), outcome.Trzba)
+	if !resent.Confirmed() {
+		t.Fatalf("resent Trzba not confirmed: %v", resent.Err)
+	}
+	if resent.Trzba.Hlavicka.PrvniZaslani {
+		t.Error("resent Trzba has PrvniZaslani set")
+	}
+	if resent.Trzba.Hlavicka.UuidZpravy != outcome.Trzba.Hlavicka.UuidZpravy || resent.Bkp() != outcome.Bkp() {
+		t.Error("resent Trzba differs in UuidZpravy or BKP")
+	}
+}
+
+func TestDispatcher_CertificateChecks(t *testing.T) {
+	var warned int
+	srv, d := newTestDispatcher(t,
+		eet.WithExpiryWarning(60*24*time.Hour, func(cert *x509.Certificate) { warned++ }),
+	)
+	defer srv.Close()
+
+	outcome := d.Submit(context.Background(), newReceipt())
+	if outcome.Err != nil {
+		t.Fatal(outcome.Err)
+	}
+	d.Submit(context.Background(), newReceipt())
+	if warned != 1 {
+		t.Errorf("expected one expiry warning, got %d", warned)
+	}
+
+	r := newReceipt()
+	r.DicPopl = "CZ683555118"
+	outcome = d.Submit(context.Background(), r)
+	var mismatchErr *eet.CertificateMismatchError
+	if !errors.As(outcome.Err, &mismatchErr) {
+		t.Fatalf("expected CertificateMismatchError, got %v", outcome.Err)
+	}
+	if outcome.Offline() {
+		t.Error("receipt with mismatching certificate must not be offline")
+	}
+
+	r.DicPoverujiciho = "CZ00000019"
+	outcome = d.Submit(context.Background(), r)
+	if outcome.Err != nil {
+		t.Errorf("unexpected error for matching dic_poverujiciho: %v", outcome.Err)
+	}
+}
+
+func TestDispatcher_SendBatch(t *testing.T) {
+	srv, d := newTestDispatcher(t)
+	defer srv.Close()
+
+	receipts := make([]eet.Receipt, 20)
+	for i := range receipts {
+		receipts[i] = newReceipt()
+	}
+	start := time.Now()
+	seen := make(map[int]bool)
+	for result := range d.SendBatch(context.Background(), receipts, eet.BatchOptions{Parallelism: 4, Rate: 100}) {
+		if seen[result.Index] {
+			t.Errorf("duplicate result for receipt %d", result.Index)
+		}
+		seen[result.Index] = true
+		if !result.Confirmed() {
+			t.Errorf("receipt %d not confirmed: %v", result.Index, result.Err)
+		}
+		if string(result.Trzba.Hlavicka.UuidZpravy) != receipts[result.Index].UuidZpravy {
+			t.Errorf("result %d carries Trzba of another receipt", result.Index)
+		}
+	}
+	if len(seen) != len(receipts) || len(srv.Requests()) != len(receipts) {
+		t.Errorf("expected %d results and requests, got %d and %d", len(receipts), len(seen), len(srv.Requests()))
+	}
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("rate limit not applied, batch took %s", elapsed)
+	}
+}
+
+func TestDispatcher_SendBatchUnlimitedRate(t *testing.T) {
+	srv, d := newTestDispatcher(t)
+	defer srv.Close()
+
+	var results int
+	for result := range d.SendBatch(context.Background(), []eet.Receipt{newReceipt(), newReceipt()}, eet.BatchOptions{Rate: 1e12}) {
+		results++
+		if !result.Confirmed() {
+			t.Errorf("receipt %d not confirmed: %v", result.Index, result.Err)
+		}
+	}
+	if results != 2 {
+		t.Errorf("expected 2 results, got %d", results)
+	}
+}
+
+func TestDispatcher_SendBatchInFlight(t *testing.T) {
+	srv, d := newTestDispatcher(t)
+	defer srv.Close()
+	srv.Enqueue(eettest.Reply{Delay: 200 * time.Millisecond}, eettest.Reply{Delay: 200 * time.Millisecond})
+
+	r := newReceipt()
+	var confirmed, inFlight int
+	for result := range d.SendBatch(context.Background(), []eet.Receipt{r, r}, eet.BatchOptions{}) {
+		switch {
+		case result.Confirmed():
+			confirmed++
+		case errors.Is(result.Err, eet.ErrInFlight) && !result.Offline():
+			inFlight++
+		default:
+			t.Errorf("unexpected error %v", result.Err)
+		}
+	}
+	if confirmed != 1 || inFlight != 1 || len(srv.Requests()) != 1 {
+		t.Errorf("expected one confirmed and one in flight, got %d and %d with %d requests", confirmed, inFlight, len(srv.Requests()))
+	}
+}
+
+func TestDispatcher_SendBatchCanceled(t *testing.T) {
+	srv, d := newTestDispatcher(t)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var results int
+	for result := range d.SendBatch(ctx, []eet.Receipt{newReceipt(), newReceipt(), newReceipt()}, eet.BatchOptions{Rate: 1}) {
+		results++
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", result.Err)
+		}
+	}
+	if results != 3 || len(srv.Requests()) != 0 {
+		t.Errorf("expected 3 results without requests, got %d and %d requests", results, len(srv.Requests()))
+	}
+}
+
+func TestDispatcher_RetryPolicy(t *testing.T) {
+	policy := eet.RetryPolicy{MaxAttempts: 3, MinBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, Jitter: 0.5}
+
+	t.Run("retryable", func(t *testing.T) {
+		srv, d := newTestDispatcher(t, eet.WithRetryPolicy(policy))
+		defer srv.Close()
+		srv.Enqueue(eettest.Reply{Status: 503}, eettest.Reply{Code: eet.CodeTemporaryError})
+
+		outcome := d.Submit(context.Background(), newReceipt())
+		if !outcome.Confirmed() {
+			t.Fatalf("expected confirmed outcome, got %v", outcome.Err)
+		}
+		requests := srv.Requests()
+		if len(requests) != 3 {
+			t.Fatalf("expected 3 attempts, got %d", len(requests))
+		}
+		for i, req := range requests {
+			h := req.Trzba.Hlavicka
+			if h.PrvniZaslani != (i == 0) {
+				t.Errorf("attempt %d sent with prvni_zaslani %t", i+1, h.PrvniZaslani)
+			}
+			if h.UuidZpravy != requests[0].Trzba.Hlavicka.UuidZpravy || req.Trzba.KontrolniKody != requests[0].Trzba.KontrolniKody {
+				t.Errorf("attempt %d differs in uuid_zpravy or control codes", i+1)
+			}
+		}
+		if outcome.Trzba.Hlavicka.PrvniZaslani {
+			t.Error("outcome Trzba has PrvniZaslani set after retries")
+		}
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		srv, d := newTestDispatcher(t, eet.WithRetryPolicy(policy))
+		defer srv.Close()
+		srv.Enqueue(eettest.Reply{Code: eet.CodeSchemaViolation})
+
+		outcome := d.Submit(context.Background(), newReceipt())
+		if !errors.Is(outcome.Err, eet.ErrSchemaViolation) || len(srv.Requests()) != 1 {
+			t.Errorf("expected single attempt with schema violation, got %d attempts and %v", len(srv.Requests()), outcome.Err)
+		}
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		srv, d := newTestDispatcher(t, eet.WithRetryPolicy(policy))
+		defer srv.Close()
+		srv.Enqueue(eettest.Reply{Status: 500}, eettest.Reply{Status: 502}, eettest.Reply{Status: 503})
+
+		outcome := d.Submit(context.Background(), newReceipt())
+		if !outcome.Offline() || len(srv.Requests()) != 3 {
+			t.Errorf("expected offline outcome after 3 attempts, got %d attempts and %v", len(srv.Requests()), outcome.Err)
+		}
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		budget := policy
+		budget.Budget = 150 * time.Millisecond
+		srv, d := newTestDispatcher(t, eet.WithRetryPolicy(budget), eet.WithTimeout(0))
+		defer srv.Close()
+		srv.Enqueue(eettest.Reply{Status: 503}, eettest.Reply{Delay: time.Second})
+
+		start := time.Now()
+		outcome := d.Submit(context.Background(), newReceipt())
+		var timeoutErr *eet.TimeoutError
+		if !errors.As(outcome.Err, &timeoutErr) || !outcome.Offline() {
+			t.Errorf("expected offline outcome with TimeoutError, got %v", outcome.Err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("budget exceeded, took %s", elapsed)
+		}
+	})
+}
+
+func TestDispatcher_WithSequencer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eet-sequence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sequencer, err := eet.NewFileSequencer(dir, eet.SequenceFormat{Prefix: "P"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, d := newTestDispatcher(t, eet.WithSequencer(sequencer))
+	defer srv.Close()
+
+	r := newReceipt()
+	r.UuidZpravy = ""
+	r.PoradCis = ""
+	invalid := r
+	invalid.IdProvoz = 0
+	if outcome := d.Submit(context.Background(), invalid); outcome.Err == nil {
+		t.Fatal("expected error for invalid id_provoz")
+	}
+
+	for _, want := range []string{"P/1", "P/2"} {
+		outcome := d.Submit(context.Background(), r)
+		if !outcome.Confirmed() {
+			t.Fatal(outcome.Err)
+		}
+		if got := string(outcome.Trzba.Data.PoradCis); got != want {
+			t.Errorf("expected porad_cis %s, got %s", want, got)
+		}
+		if _, err := uuid.FromString(string(outcome.Trzba.Hlavicka.UuidZpravy)); err != nil {
+			t.Errorf("invalid generated uuid_zpravy: %v", err)
+		}
+	}
+}
+
+func TestDispatcher_WithObserver(t *testing.T) {
+	var exchanges []eet.Exchange
+	var logged bytes.Buffer
+	logObserver := eet.NewLogObserver(log.New(&logged, "", 0), eet.LogMessages(), eet.LogRedacted())
+	srv, d := newTestDispatcher(t,
+		eet.WithRetryPolicy(eet.RetryPolicy{MaxAttempts: 2}),
+		eet.WithObserver(eet.ObserverFunc(func(ex eet.Exchange) {
+			exchanges = append(exchanges, ex)
+			logObserver.Observe(ex)
+		})),
+	)
+	defer srv.Close()
+	srv.Enqueue(eettest.Reply{Status: 503})
+
+	outcome := d.Submit(context.Background(), newReceipt())
+	if !outcome.Confirmed() {
+		t.Fatal(outcome.Err)
+	}
+	if len(exchanges) != 2 {
+		t.Fatalf("expected 2 exchanges, got %d", len(exchanges))
+	}
+	failed, confirmed := exchanges[0], exchanges[1]
+	if failed.Attempt != 1 || failed.StatusCode != 503 || failed.Err == nil {
+		t.Errorf("unexpected first exchange %+v", failed)
+	}
+	if confirmed.Attempt != 2 || confirmed.StatusCode != 200 || confirmed.Response != outcome.Response {
+		t.Errorf("unexpected second exchange %+v", confirmed)
+	}
+	if !bytes.Equal(confirmed.RequestBody, srv.Requests()[1].Body) {
+		t.Error("exchange request differs from the received message")
+	}
+	if !bytes.Contains(confirmed.ResponseBody, []byte(outcome.Response.Fik)) {
+		t.Error("exchange response does not contain FIK")
+	}
+	if confirmed.Duration < confirmed.Signing+confirmed.Network+confirmed.Decoding || confirmed.Network == 0 {
+		t.Errorf("inconsistent timings %+v", confirmed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logged.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %q", logged.String())
+	}
+	for _, want := range []string{"attempt=2", "status=200", "fik=" + outcome.Response.Fik, "prvni_zaslani=false", "REDACTED"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("log line misses %q: %s", want, lines[1])
+		}
+	}
+	if strings.Contains(logged.String(), outcome.Pkp()) {
+		t.Error("PKP not redacted")
+	}
+	if !strings.Contains(lines[0], `error="eet: unexpected HTTP status 503 Service Unavailable"`) {
+		t.Errorf("log line misses error: %s", lines[0])
+	}
+}
+
+func TestDispatcher_WithMetrics(t *testing.T) {
+	collector := eet.NewCollector()
+	srv, d := newTestDispatcher(t, eet.WithMetrics(collector))
+	defer srv.Close()
+	srv.Enqueue(
+		eettest.Reply{Warnings: []eet.WarningCode{eet.WarningDatTrzbyFarInPast}},
+		eettest.Reply{Code: eet.CodeSchemaViolation},
+		eettest.Reply{Status: 503},
+	)
+	for i := 0; i < 3; i++ {
+		d.Submit(context.Background(), newReceipt())
+	}
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`eet_receipts_total{result="confirmed"} 1`,
+		`eet_receipts_total{result="offline"} 1`,
+		`eet_receipts_total{result="rejected"} 1`,
+		`eet_attempts_total{result="accepted"} 1`,
+		`eet_attempts_total{result="http_error"} 1`,
+		`eet_attempts_total{result="rejected"} 1`,
+		`eet_rejected_total{code="3"} 1`,
+		fmt.Sprintf(`eet_warnings_total{code="%d"} 1`, eet.WarningDatTrzbyFarInPast),
+		`eet_attempt_duration_seconds_count 3`,
+		`eet_signing_duration_seconds_bucket{le="+Inf"} 3`,
+		`eet_network_duration_seconds_count 3`,
+		`# TYPE eet_decoding_duration_seconds histogram`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics miss %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestDispatcher_ResponseChecks(t *testing.T) {
+	otherUUID := uuid.Must(uuid.NewV4()).String()
+	otherBkp := "01234567-89abcdef-01234567-89abcdef-01234567"
+	tests := []struct {
+		name  string
+		reply eettest.Reply
+		field string
+	}{
+		{"uuid_zpravy of another message", eettest.Reply{UuidZpravy: otherUUID}, "uuid_zpravy"},
+		{"bkp of another message", eettest.Reply{Bkp: otherBkp}, "bkp"},
+		{"chyba for another message", eettest.Reply{Code: eet.CodeSchemaViolation, UuidZpravy: otherUUID}, "uuid_zpravy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, d := newTestDispatcher(t)
+			defer srv.Close()
+			srv.Enqueue(tt.reply)
+
+			_, err := d.SendPayment(newReceipt())
+			var mismatchErr *eet.MismatchError
+			if !errors.As(err, &mismatchErr) || mismatchErr.Field != tt.field {
+				t.Fatalf("expected MismatchError of %s, got %v", tt.field, err)
+			}
+			if errors.Is(err, eet.ErrSchemaViolation) {
+				t.Error("Chyba of another message returned")
+			}
+		})
+	}
+}
+
+func TestDispatcher_TestResponseFromProduction(t *testing.T) {
+	srv, err := eettest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	signer, err := srv.CA.NewSigner("CZ00000019")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// requests to prod.eet.cz are sent to the fake service
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	client := &http.Client{Transport: transport}
+
+	for _, service := range []eet.Service{
+		"http://prod.eet.cz/eet/services/EETServiceSOAP/v3/",
+		"http://PROD.EET.CZ:80/eet/services/EETServiceSOAP/v3",
+	} {
+		d, err := eet.NewDispatcher(service, signer, eet.WithHTTPClient(client), eet.WithResponseRoots(srv.CA.Pool()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.SendPayment(newReceipt()); !errors.Is(err, eet.ErrTestResponse) {
+			t.Errorf("%s: expected ErrTestResponse, got %v", service, err)
+		}
+		srv.Enqueue(eettest.Reply{Production: true})
+		if response, err := d.SendPayment(newReceipt()); err != nil || response.Test {
+			t.Errorf("%s: expected production response, got %v", service, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package eettest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/prochac/eet"
+)
+
+// CA is a generated certificate authority issuing certificates of taxpayers
+// and of the fake EET service, like EET CA 1 Playground does.
+type CA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+// NewCA generates a CA valid for a year.
+func NewCA() (*CA, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: "EET CA Test"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("creating CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{cert: cert, key: key}, nil
+}
+
+// Certificate returns the certificate of the CA.
+func (ca *CA) Certificate() *x509.Certificate {
+	return ca.cert
+}
+
+// Pool returns a pool with the CA certificate, e.g. for eet.WithResponseRoots.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// NewSigner issues a certificate with the common name cn, e.g. the DIC
+// of a taxpayer, valid for 30 days.
+func (ca *CA) NewSigner(cn string) (*eet.Signer, error) {
+	now := time.Now()
+	return ca.NewSignerValid(cn, now.Add(-time.Hour), now.AddDate(0, 0, 30))
+}
+
+// NewSignerValid is like NewSigner with the given validity of the certificate,
+// e.g. to test expiring certificates.
+func (ca *CA) NewSignerValid(cn string, notBefore, notAfter time.Time) (*eet.Signer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("creating certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return eet.NewCryptoSigner(key, cert, ca.cert)
+}
@@ -0,0 +1,347 @@
+// Package eettest provides a fake EET service for integration tests.
+//
+// The Server verifies incoming messages like the real service does and
+// responds with signed Odpoved messages, so a Dispatcher can be tested
+// without network access:
+//
+//	srv, err := eettest.NewServer()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer srv.Close()
+//	signer, err := srv.CA.NewSigner("CZ00000019")
+//	...
+//	d, err := eet.NewDispatcher(srv.Service(), signer, eet.WithResponseRoots(srv.CA.Pool()))
+//
+// Replies to the following requests can be scripted by Enqueue, e.g. to
+// return Chyba codes, warnings, delays or malformed XML.
+package eettest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prochac/eet"
+)
+
+// Reply scripts the response to one request. The zero Reply processes the
+// request like the real service.
+type Reply struct {
+	// Code rejects the message with Chyba of the code, unless zero.
+	Code eet.ErrorCode
+	// Warnings are added to the response as Varovani.
+	Warnings []eet.WarningCode
+	// Delay postpones the response, e.g. to test timeouts.
+	Delay time.Duration
+	// Status responds with the HTTP status and Body instead of Odpoved.
+	Status int
+	// Body is sent instead of Odpoved, e.g. malformed XML.
+	Body []byte
+	// Unsigned omits the WS-Security signature of the response.
+	Unsigned bool
+	// Production omits the test flag of the response, as the production service does.
+	Production bool
+	// Overeni responds with Chyba of code 0 as to a message sent in
+	// verification mode, even if it was not.
+	Overeni bool
+	// UuidZpravy and Bkp replace the values echoed in the response header,
+	// e.g. to respond to another message.
+	UuidZpravy string
+	Bkp        string
+}
+
+// Request is a message received by the Server.
+type Request struct {
+	// Body is the raw SOAP envelope.
+	Body []byte
+	// Trzba is the decoded message, empty if it could not be decoded.
+	Trzba eet.Trzba
+	// Fik is the FIK of a confirmed message.
+	Fik string
+	// Err is the reason the message was rejected, nil if it was accepted.
+	Err error
+}
+
+// Server is a fake EET service. It checks the WS-Security signature, the
+// XML schema and BKP of every message, rejecting invalid ones with Chyba,
+// and reports an invalid PKP or a certificate not issued to the taxpayer by
+// Varovani. Confirmed responses carry a random FIK and are flagged as test,
+// as on the playground, unless Reply.Production is set.
+type Server struct {
+	*httptest.Server
+	// CA issues the certificate of the service, accepted signers have to be
+	// issued by it as well.
+	CA *CA
+
+	signer *eet.Signer
+
+	mu       sync.Mutex
+	replies  []Reply
+	requests []Request
+}
+
+// NewServer starts a Server with a generated CA. The caller should call
+// Close when finished, to shut it down.
+func NewServer() (*Server, error) {
+	ca, err := NewCA()
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ca.NewSigner("EET Test Service")
+	if err != nil {
+		return nil, err
+	}
+	s := Server{CA: ca, signer: signer}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return &s, nil
+}
+
+// Service returns the service URL for eet.NewDispatcher.
+func (s *Server) Service() eet.Service {
+	return eet.Service(s.URL)
+}
+
+// Enqueue scripts the replies to the following requests, one per request
+// in the given order. Requests beyond the script get the zero Reply.
+func (s *Server) Enqueue(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// Requests returns all messages received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) nextReply() Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.replies) == 0 {
+		return Reply{}
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return reply
+}
+
+func (s *Server) record(req Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reply := s.nextReply()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if reply.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(reply.Delay):
+		}
+	}
+	if reply.Status != 0 {
+		s.record(Request{Body: body, Trzba: decodeTrzba(body), Err: fmt.Errorf("scripted HTTP status %d", reply.Status)})
+		w.WriteHeader(reply.Status)
+		_, _ = w.Write(reply.Body)
+		return
+	}
+	if reply.Body != nil {
+		s.record(Request{Body: body, Trzba: decodeTrzba(body), Err: errors.New("scripted body")})
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		_, _ = w.Write(reply.Body)
+		return
+	}
+
+	odpoved, req := s.process(body, reply)
+	s.record(req)
+	if reply.UuidZpravy != "" {
+		odpoved.Hlavicka.UuidZpravy = reply.UuidZpravy
+	}
+	if reply.Bkp != "" {
+		odpoved.Hlavicka.Bkp = reply.Bkp
+	}
+
+	var content interface{} = unsignedEnvelope{Body: unsignedBody{Odpoved: odpoved}}
+	if !reply.Unsigned {
+		envelope, err := eet.NewSOAPEnvelopeRequest(odpoved, s.signer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content = envelope
+	}
+	data, err := xml.Marshal(content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+}
+
+// decodeTrzba returns the Trzba of a request answered by a scripted reply,
+// empty if it could not be decoded.
+func decodeTrzba(body []byte) eet.Trzba {
+	var envelope requestEnvelope
+	_ = xml.Unmarshal(body, &envelope)
+	return envelope.Body.Trzba
+}
+
+// process checks the message and builds the response.
+func (s *Server) process(body []byte, reply Reply) (odpoved, Request) {
+	now := string(eet.NewDateTimeType(time.Now()))
+	req := Request{Body: body}
+	var o odpoved
+
+	reject := func(code eet.ErrorCode, err error) (odpoved, Request) {
+		o.Hlavicka.DatOdmit = now
+		o.Chyba = &chyba{Kod: code, Test: !reply.Production, Text: code.Czech()}
+		req.Err = err
+		return o, req
+	}
+
+	var envelope requestEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		return reject(eet.CodeInvalidEncoding, err)
+	}
+	trzba := envelope.Body.Trzba
+	req.Trzba = trzba
+	o.Hlavicka.UuidZpravy = string(trzba.Hlavicka.UuidZpravy)
+	o.Hlavicka.Bkp = trzba.KontrolniKody.Bkp.Value
+
+	cert, _, err := eet.VerifySignature(body, s.CA.Pool())
+	if err != nil {
+		return reject(eet.CodeInvalidSOAPSignature, err)
+	}
+	if err := eet.ValidateTrzba(trzba); err != nil {
+		return reject(eet.CodeSchemaViolation, err)
+	}
+	bkp, err := eet.NewBkp(trzba.KontrolniKody.Pkp)
+	if err != nil || !strings.EqualFold(bkp.Value, trzba.KontrolniKody.Bkp.Value) {
+		return reject(eet.CodeInvalidBkp, fmt.Errorf("BKP %s does not match PKP", trzba.KontrolniKody.Bkp.Value))
+	}
+
+	for _, code := range reply.Warnings {
+		o.Varovani = append(o.Varovani, varovani{KodVarov: code, Text: code.String()})
+	}
+	if !strings.Contains(cert.Subject.CommonName, string(trzba.Data.DicPopl)) &&
+		(trzba.Data.DicPoverujiciho == "" || !strings.Contains(cert.Subject.CommonName, string(trzba.Data.DicPoverujiciho))) {
+		o.Varovani = append(o.Varovani, varovani{KodVarov: eet.WarningDicMismatch, Text: eet.WarningDicMismatch.String()})
+	}
+	if err := verifyPkp(trzba, cert.PublicKey); err != nil {
+		o.Varovani = append(o.Varovani, varovani{KodVarov: eet.WarningInvalidPkp, Text: eet.WarningInvalidPkp.String()})
+	}
+
+	if reply.Code != eet.CodeVerificationOK {
+		return reject(reply.Code, eet.Chyba{Kod: reply.Code, Chyba: reply.Code.Czech()})
+	}
+	if trzba.Hlavicka.Overeni || reply.Overeni {
+		o.Hlavicka.DatOdmit = now
+		o.Chyba = &chyba{Kod: eet.CodeVerificationOK, Test: !reply.Production, Text: eet.CodeVerificationOK.Czech()}
+		return o, req
+	}
+
+	o.Hlavicka.DatPrij = now
+	req.Fik = newFik()
+	o.Potvrzeni = &potvrzeni{Fik: req.Fik, Test: !reply.Production}
+	return o, req
+}
+
+// verifyPkp checks PKP of trzba against the public key of the certificate.
+func verifyPkp(trzba eet.Trzba, publicKey crypto.PublicKey) error {
+	key, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("certificate key is not RSA")
+	}
+	sig, err := base64.StdEncoding.DecodeString(trzba.KontrolniKody.Pkp.Value)
+	if err != nil {
+		return err
+	}
+	d := trzba.Data
+	pkpStr := fmt.Sprintf("%s|%s|%s|%s|%s|%s", d.DicPopl, d.IdProvoz, d.IdPokl, d.PoradCis, d.DatTrzby, d.CelkTrzba)
+	hashed := sha256.Sum256([]byte(pkpStr))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig)
+}
+
+// newFik generates a random FIK in the format of FikType.
+func newFik() string {
+	b := make([]byte, 17)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32] + "-" + h[32:34]
+}
+
+type requestEnvelope struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	Body    struct {
+		Trzba eet.Trzba
+	} `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+}
+
+type unsignedEnvelope struct {
+	XMLName xml.Name     `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	Body    unsignedBody `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+}
+
+type unsignedBody struct {
+	Odpoved odpoved
+}
+
+type odpoved struct {
+	XMLName  xml.Name `xml:"http://fs.mfcr.cz/eet/schema/v3 Odpoved"`
+	Hlavicka struct {
+		UuidZpravy string `xml:"uuid_zpravy,attr,omitempty"`
+		Bkp        string `xml:"bkp,attr,omitempty"`
+		DatPrij    string `xml:"dat_prij,attr,omitempty"`
+		DatOdmit   string `xml:"dat_odmit,attr,omitempty"`
+	} `xml:"Hlavicka"`
+	Potvrzeni *potvrzeni `xml:"Potvrzeni"`
+	Chyba     *chyba     `xml:"Chyba"`
+	Varovani  []varovani `xml:"Varovani"`
+}
+
+type potvrzeni struct {
+	Fik  string `xml:"fik,attr"`
+	Test bool   `xml:"test,attr,omitempty"`
+}
+
+type chyba struct {
+	Kod  eet.ErrorCode `xml:"kod,attr"`
+	Test bool          `xml:"test,attr,omitempty"`
+	Text string        `xml:",chardata"`
+}
+
+type varovani struct {
+	KodVarov eet.WarningCode `xml:"kod_varov,attr"`
+	Text     string          `xml:",chardata"`
+}
@@ -0,0 +1,89 @@
+package eettest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/prochac/eet"
+)
+
+func TestServer_RejectsInvalidMessages(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	signer, err := srv.CA.NewSigner("CZ00000019")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCA, err := NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrusted, err := otherCA.NewSigner("CZ00000019")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		signer *eet.Signer
+		modify func(*eet.Trzba)
+		code   string
+	}{
+		{name: "invalid BKP", signer: signer, modify: func(tr *eet.Trzba) {
+			tr.KontrolniKody.Bkp.Value = "00000000-00000000-00000000-00000000-00000000"
+		}, code: `kod="5"`},
+		{name: "untrusted certificate", signer: untrusted, modify: func(*eet.Trzba) {}, code: `kod="4"`},
+		{name: "schema violation", signer: signer, modify: func(tr *eet.Trzba) {
+			tr.Data.PoradCis = "not/valid/!"
+		}, code: `kod="3"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trzba, err := eet.Receipt{
+				UuidZpravy:   uuid.Must(uuid.NewV4()).String(),
+				PrvniZaslani: true,
+				DicPopl:      "CZ00000019",
+				IdProvoz:     273,
+				IdPokl:       "/5546/RO24",
+				PoradCis:     "0/6460/ZQ42",
+				DatTrzby:     time.Now(),
+			}.Trzba(tt.signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(&trzba)
+			envelope, err := eet.NewSOAPEnvelopeRequest(trzba, tt.signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := xml.Marshal(envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := http.Post(srv.URL, "application/xml", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var buf bytes.Buffer
+			if _, err := buf.ReadFrom(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tt.code) {
+				t.Errorf("expected Chyba %s, got %s", tt.code, buf.String())
+			}
+			if requests := srv.Requests(); requests[len(requests)-1].Err == nil {
+				t.Error("request not recorded as rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package eet
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// ErrorCode is the code of an error reported by the EET server in Chyba.
+type ErrorCode int
+
+// Error codes documented by the EET interface specification.
+const (
+	CodeTemporaryError       ErrorCode = -1
+	CodeVerificationOK       ErrorCode = 0
+	CodeInvalidEncoding      ErrorCode = 2
+	CodeSchemaViolation      ErrorCode = 3
+	CodeInvalidSOAPSignature ErrorCode = 4
+	CodeInvalidBkp           ErrorCode = 5
+	CodeInvalidDic           ErrorCode = 6
+	CodeMessageTooLarge      ErrorCode = 7
+	CodeProcessingError      ErrorCode = 8
+)
+
+var errorMessages = map[ErrorCode][2]string{
+	CodeTemporaryError:       {"temporary technical error, send the message later", "Dočasná technická chyba zpracování – odešlete prosím datovou zprávu později"},
+	CodeVerificationOK:       {"message sent in verification mode was processed successfully", "Datovou zprávu evidované tržby v ověřovacím módu se podařilo zpracovat"},
+	CodeInvalidEncoding:      {"invalid XML encoding", "Kódování XML není platné"},
+	CodeSchemaViolation:      {"message does not conform to the XML schema", "XML zpráva nevyhověla kontrole XML schématu"},
+	CodeInvalidSOAPSignature: {"invalid signature of the SOAP message", "Neplatný podpis SOAP zprávy"},
+	CodeInvalidBkp:           {"invalid taxpayer's security code (BKP)", "Neplatný kontrolní bezpečnostní kód poplatníka (BKP)"},
+	CodeInvalidDic:           {"taxpayer's DIC has invalid structure", "DIČ poplatníka má chybnou strukturu"},
+	CodeMessageTooLarge:      {"message is too large", "Datová zpráva je příliš velká"},
+	CodeProcessingError:      {"message was not processed due to a technical or data error", "Datová zpráva nebyla zpracována kvůli technické chybě nebo chybě dat"},
+}
+
+// String returns the English description of the code.
+func (c ErrorCode) String() string {
+	if m, ok := errorMessages[c]; ok {
+		return m[0]
+	}
+	return "unknown error"
+}
+
+// Czech returns the Czech description of the code as documented by the specification.
+func (c ErrorCode) Czech() string {
+	if m, ok := errorMessages[c]; ok {
+		return m[1]
+	}
+	return "Neznámá chyba"
+}
+
+// Sentinel errors matching a *Chyba with the same code using errors.Is.
+var (
+	ErrTemporary            error = newChyba(CodeTemporaryError)
+	ErrInvalidEncoding      error = newChyba(CodeInvalidEncoding)
+	ErrSchemaViolation      error = newChyba(CodeSchemaViolation)
+	ErrInvalidSOAPSignature error = newChyba(CodeInvalidSOAPSignature)
+	ErrInvalidBkp           error = newChyba(CodeInvalidBkp)
+	ErrInvalidDic           error = newChyba(CodeInvalidDic)
+	ErrMessageTooLarge      error = newChyba(CodeMessageTooLarge)
+	ErrProcessing           error = newChyba(CodeProcessingError)
+)
+
+func newChyba(code ErrorCode) *Chyba {
+	return &Chyba{Kod: code, Chyba: code.Czech()}
+}
+
+// IsTemporary reports whether err is a temporary error of the EET server (code -1),
+// the same message should be sent again later.
+func IsTemporary(err error) bool {
+	var ch *Chyba
+	return errors.As(err, &ch) && ch.Temporary()
+}
+
+// IsRetryable reports whether sending the same message again may succeed:
+// temporary EET errors, timeouts, HTTP 5xx responses and network errors,
+// i.e. failed dials, DNS lookups and reset or closed connections.
+// Errors in the message itself, e.g. schema or signature errors, are not
+// retryable, neither are errors of the configuration, e.g. a certificate of
+// the server not trusted by the TLS config or an unsupported URL scheme.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsTemporary(err) {
+		return true
+	}
+	var ch *Chyba
+	if errors.As(err, &ch) {
+		return false
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return true
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= http.StatusInternalServerError
+	}
+	if isConfigError(err) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return true
+	}
+	for _, target := range connectionErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// connectionErrors are errors of a connection failed or closed by the peer.
+var connectionErrors = []error{
+	syscall.ECONNREFUSED,
+	syscall.ECONNRESET,
+	syscall.ECONNABORTED,
+	syscall.ETIMEDOUT,
+	syscall.EHOSTUNREACH,
+	syscall.ENETUNREACH,
+	syscall.EPIPE,
+	io.EOF,
+	io.ErrUnexpectedEOF,
+}
+
+// isConfigError reports whether err is a TLS or certificate verification
+// error, which would repeat on every attempt.
+func isConfigError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	var recordHeader tls.RecordHeaderError
+	return errors.As(err, &unknownAuthority) ||
+		errors.As(err, &invalid) ||
+		errors.As(err, &hostname) ||
+		errors.As(err, &recordHeader)
+}
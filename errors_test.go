@@ -0,0 +1,74 @@
+package eet
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		is        error
+		temporary bool
+		retryable bool
+	}{
+		{"temporary", &Chyba{Kod: -1, Chyba: "Dočasná technická chyba"}, ErrTemporary, true, true},
+		{"wrapped schema violation", fmt.Errorf("sending: %w", &Chyba{Kod: 3}), ErrSchemaViolation, false, false},
+		{"timeout", &TimeoutError{Err: context.DeadlineExceeded}, context.DeadlineExceeded, false, true},
+		{"service unavailable", &HTTPError{StatusCode: 503}, nil, false, true},
+		{"bad request", &HTTPError{StatusCode: 400}, nil, false, false},
+		{"connection refused", &url.Error{Op: "Post", URL: "https://pg.eet.cz", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}}, syscall.ECONNREFUSED, false, true},
+		{"DNS error", &url.Error{Op: "Post", URL: "https://pg.eet.cz", Err: &net.DNSError{Err: "no such host", Name: "pg.eet.cz"}}, nil, false, true},
+		{"connection reset", fmt.Errorf("Failed to read response: %w", syscall.ECONNRESET), syscall.ECONNRESET, false, true},
+		{"connection closed", &url.Error{Op: "Post", URL: "https://pg.eet.cz", Err: io.EOF}, io.EOF, false, true},
+		{"client timeout", &url.Error{Op: "Post", URL: "https://pg.eet.cz", Err: context.DeadlineExceeded}, context.DeadlineExceeded, false, true},
+		{"unknown authority", &url.Error{Op: "Post", URL: "https://pg.eet.cz", Err: x509.UnknownAuthorityError{}}, nil, false, false},
+		{"expired certificate", &url.Error{Op: "Post", URL: "https://pg.eet.cz", Err: x509.CertificateInvalidError{Reason: x509.Expired}}, nil, false, false},
+		{"hostname mismatch", &url.Error{Op: "Post", URL: "https://pg.eet.cz", Err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "pg.eet.cz"}}, nil, false, false},
+		{"not TLS", &url.Error{Op: "Post", URL: "https://pg.eet.cz", Err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}}, nil, false, false},
+		{"unsupported scheme", &url.Error{Op: "Post", URL: "ftp://pg.eet.cz", Err: errors.New(`unsupported protocol scheme "ftp"`)}, nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.is != nil && !errors.Is(tt.err, tt.is) {
+				t.Errorf("errors.Is(%v, %v) = false", tt.err, tt.is)
+			}
+			if got := IsTemporary(tt.err); got != tt.temporary {
+				t.Errorf("IsTemporary() = %v, want %v", got, tt.temporary)
+			}
+			if got := IsRetryable(tt.err); got != tt.retryable {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+	if errors.Is(&Chyba{Kod: 3}, ErrInvalidBkp) {
+		t.Error("schema violation matches ErrInvalidBkp")
+	}
+}
+
+func TestErrorCode_Czech(t *testing.T) {
+	tests := []struct {
+		code ErrorCode
+		want string
+	}{
+		{CodeTemporaryError, "Dočasná technická chyba zpracování – odešlete prosím datovou zprávu později"},
+		{CodeInvalidSOAPSignature, "Neplatný podpis SOAP zprávy"},
+		{CodeInvalidDic, "DIČ poplatníka má chybnou strukturu"},
+		{ErrorCode(42), "Neznámá chyba"},
+	}
+	for _, tt := range tests {
+		if got := tt.code.Czech(); got != tt.want {
+			t.Errorf("ErrorCode(%d).Czech() = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
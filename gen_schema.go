@@ -0,0 +1,42 @@
+//go:build ignore
+// +build ignore
+
+// gen_schema writes schema.go with EETSchema set to the official
+// EETXMLSchema.xsd vendored in testdata, byte for byte.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"strconv"
+)
+
+func main() {
+	xsd, err := ioutil.ReadFile("testdata/EETXMLSchema.xsd")
+	if err != nil {
+		log.Fatal(err)
+	}
+	literal := "`" + string(xsd) + "`"
+	if bytes.ContainsAny(xsd, "`\r") {
+		literal = strconv.Quote(string(xsd))
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by gen_schema.go from testdata/EETXMLSchema.xsd. DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package eet")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// EETSchema is the official EETXMLSchema.xsd of the EET interface")
+	fmt.Fprintln(&buf, "// specification v3, used by ValidateXML.")
+	fmt.Fprintf(&buf, "const EETSchema = %s\n", literal)
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("schema.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,300 @@
+package eet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoSigner is returned by a Keystore without a signer for the taxpayer.
+var ErrNoSigner = errors.New("eet: no signer for taxpayer")
+
+// Keystore provides signers of multiple taxpayers, so a single Dispatcher
+// can send receipts of all of them, see WithKeystore.
+type Keystore interface {
+	// Signer returns the signer for receipts of the taxpayer dic issued in
+	// the establishment idProvoz, or an error matching ErrNoSigner.
+	Signer(dic CZDICType, idProvoz IdProvozType) (*Signer, error)
+}
+
+type registryKey struct {
+	dic      CZDICType
+	idProvoz IdProvozType
+}
+
+// Registry is a Keystore of signers registered by the DIC of their
+// certificate, optionally only for an establishment. It is safe for
+// concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	signers map[registryKey]*Signer
+}
+
+func NewRegistry() *Registry {
+	return &Registry{signers: map[registryKey]*Signer{}}
+}
+
+// Add registers signer for the DIC in its certificate. With idProvoz the
+// signer is used only for receipts of these establishments, otherwise for
+// all establishments without their own signer. A previously added signer
+// for the same DIC and establishment is replaced.
+func (r *Registry) Add(signer *Signer, idProvoz ...IdProvozType) error {
+	dic, err := signer.DIC()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(idProvoz) == 0 {
+		r.signers[registryKey{dic: dic}] = signer
+	}
+	for _, id := range idProvoz {
+		r.signers[registryKey{dic: dic, idProvoz: id}] = signer
+	}
+	return nil
+}
+
+// Remove unregisters the signer of dic for the establishments idProvoz, or
+// the one for all establishments when idProvoz is empty.
+func (r *Registry) Remove(dic CZDICType, idProvoz ...IdProvozType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(idProvoz) == 0 {
+		delete(r.signers, registryKey{dic: dic})
+	}
+	for _, id := range idProvoz {
+		delete(r.signers, registryKey{dic: dic, idProvoz: id})
+	}
+}
+
+// Signer returns the signer registered for the establishment, or else the
+// one registered for all establishments of dic.
+func (r *Registry) Signer(dic CZDICType, idProvoz IdProvozType) (*Signer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if signer, ok := r.signers[registryKey{dic: dic, idProvoz: idProvoz}]; ok {
+		return signer, nil
+	}
+	if signer, ok := r.signers[registryKey{dic: dic}]; ok {
+		return signer, nil
+	}
+	return nil, fmt.Errorf("%w %s in establishment %d", ErrNoSigner, dic, idProvoz)
+}
+
+// replace swaps all registered signers at once.
+func (r *Registry) replace(signers map[registryKey]*Signer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.signers = signers
+}
+
+// signerFor returns the signer of the keystore for the taxpayer, falling back
+// to the signer passed to NewDispatcher.
+func (d *Dispatcher) signerFor(dic CZDICType, idProvoz IdProvozType) (*Signer, error) {
+	if d.keystore == nil {
+		return d.signer, nil
+	}
+	signer, err := d.keystore.Signer(dic, idProvoz)
+	if errors.Is(err, ErrNoSigner) && d.signer != nil {
+		return d.signer, nil
+	}
+	return signer, err
+}
+
+// KeystoreErrors lists the files a DirKeystore failed to load.
+type KeystoreErrors []error
+
+func (e KeystoreErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// PasswordFunc returns the password of the PKCS#12 file at path.
+type PasswordFunc func(path string) (string, error)
+
+// Password returns a PasswordFunc using the same password for all files.
+func Password(password string) PasswordFunc {
+	return func(string) (string, error) {
+		return password, nil
+	}
+}
+
+// DirKeystore is a Keystore loading .p12 and .pfx files from a directory.
+// The DIC is taken from the certificate. A file named "<DIC>-<id_provoz>",
+// e.g. CZ00000019-11.p12, is used only for the establishment 11 and the DIC
+// in the name has to match the certificate. Files named otherwise, e.g.
+// CZ00000019.p12 or renewed-2024.p12, are used for all establishments of the
+// taxpayer. When more files apply to the same taxpayer and establishment,
+// e.g. an old and a renewed certificate, the one valid now and expiring
+// last is used.
+type DirKeystore struct {
+	dir      string
+	password PasswordFunc
+	registry *Registry
+
+	mu    sync.Mutex
+	files map[string]keystoreFile
+}
+
+type keystoreFile struct {
+	modTime time.Time
+	size    int64
+	signer  *Signer
+}
+
+// NewDirKeystore loads the signers from dir. Any file which cannot be loaded
+// is reported as an error.
+func NewDirKeystore(dir string, password PasswordFunc) (*DirKeystore, error) {
+	k := DirKeystore{
+		dir:      dir,
+		password: password,
+		registry: NewRegistry(),
+		files:    map[string]keystoreFile{},
+	}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// Signer implements Keystore.
+func (k *DirKeystore) Signer(dic CZDICType, idProvoz IdProvozType) (*Signer, error) {
+	return k.registry.Signer(dic, idProvoz)
+}
+
+// Reload loads new and modified files and drops removed ones. A file which
+// cannot be loaded keeps its previous signer, if any, and is reported in the
+// returned error, the other files are still reloaded.
+func (k *DirKeystore) Reload() error {
+	entries, err := ioutil.ReadDir(k.dir)
+	if err != nil {
+		return fmt.Errorf("reading keystore directory: %w", err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	var errs KeystoreErrors
+	files := map[string]keystoreFile{}
+	signers := map[registryKey]*Signer{}
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || ext != ".p12" && ext != ".pfx" {
+			continue
+		}
+		path := filepath.Join(k.dir, entry.Name())
+
+		file, ok := k.files[path]
+		if !ok || !file.modTime.Equal(entry.ModTime()) || file.size != entry.Size() {
+			signer, err := k.load(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("loading %s: %w", path, err))
+				if !ok {
+					continue
+				}
+			} else {
+				file = keystoreFile{modTime: entry.ModTime(), size: entry.Size(), signer: signer}
+			}
+		}
+		files[path] = file
+
+		dic, _ := file.signer.DIC()
+		nameDic, idProvoz := keystoreName(entry.Name())
+		if nameDic != "" && nameDic != dic {
+			errs = append(errs, fmt.Errorf("loading %s: file name does not match certificate DIC %s", path, dic))
+			continue
+		}
+		key := registryKey{dic: dic, idProvoz: idProvoz}
+		signers[key] = preferredSigner(signers[key], file.signer, now)
+	}
+	k.files = files
+	k.registry.replace(signers)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (k *DirKeystore) load(path string) (*Signer, error) {
+	password, err := k.password(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := NewSigner(path, password)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := signer.DIC(); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// keystoreNamePattern matches names of files with signers of an establishment,
+// <DIC>-<id_provoz>, e.g. CZ00000019-11.
+var keystoreNamePattern = regexp.MustCompile(`^(CZ[0-9]{8,10})-([1-9][0-9]{0,5})$`)
+
+// keystoreName returns the DIC and the establishment in the name of the file,
+// empty and 0 when the name does not match keystoreNamePattern.
+func keystoreName(name string) (CZDICType, IdProvozType) {
+	m := keystoreNamePattern.FindStringSubmatch(strings.TrimSuffix(name, filepath.Ext(name)))
+	if m == nil {
+		return "", 0
+	}
+	id, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0
+	}
+	return CZDICType(m[1]), IdProvozType(id)
+}
+
+// preferredSigner returns the signer to use of two with the same DIC and
+// establishment, e.g. an old and a renewed certificate. The one valid at now
+// wins, then the one expiring later.
+func preferredSigner(a, b *Signer, now time.Time) *Signer {
+	if a == nil {
+		return b
+	}
+	aValid, bValid := a.CheckValidity(now) == nil, b.CheckValidity(now) == nil
+	if aValid != bValid {
+		if aValid {
+			return a
+		}
+		return b
+	}
+	if b.NotAfter().After(a.NotAfter()) {
+		return b
+	}
+	return a
+}
+
+// Watch reloads the directory every interval until ctx is done, so rotated
+// certificates are picked up without a restart. Errors of Reload are passed
+// to onError, which may be nil.
+func (k *DirKeystore) Watch(ctx context.Context, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := k.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
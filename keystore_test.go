@@ -0,0 +1,226 @@
+package eet
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestRegistry(t *testing.T) {
+	ca := newTestCA(t)
+	popl := ca.newSigner(t, "CZ00000019")
+	provoz := ca.newSigner(t, "CZ00000019")
+
+	registry := NewRegistry()
+	if err := registry.Add(popl); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Add(provoz, 11); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Add(ca.newSigner(t, "EET server")); err == nil {
+		t.Error("expected error for certificate without DIC")
+	}
+
+	tests := []struct {
+		dic      CZDICType
+		idProvoz IdProvozType
+		want     *Signer
+	}{
+		{dic: "CZ00000019", idProvoz: 273, want: popl},
+		{dic: "CZ00000019", idProvoz: 11, want: provoz},
+		{dic: "CZ683555118", idProvoz: 11},
+	}
+	for _, tt := range tests {
+		got, err := registry.Signer(tt.dic, tt.idProvoz)
+		if tt.want == nil {
+			if !errors.Is(err, ErrNoSigner) {
+				t.Errorf("Signer(%s, %d) error = %v, want ErrNoSigner", tt.dic, tt.idProvoz, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Signer(%s, %d) returned unexpected signer, error %v", tt.dic, tt.idProvoz, err)
+		}
+	}
+
+	registry.Remove("CZ00000019", 11)
+	if got, _ := registry.Signer("CZ00000019", 11); got != popl {
+		t.Error("removed establishment signer still used")
+	}
+}
+
+func TestDirKeystore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eet-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	copyFile := func(name string) {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "keystore", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copyFile("CZ00000019.p12")
+
+	ks, err := NewDirKeystore(dir, Password("eet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Signer("CZ00000019", 273); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Signer("CZ683555118", 11); !errors.Is(err, ErrNoSigner) {
+		t.Fatalf("expected ErrNoSigner, got %v", err)
+	}
+
+	copyFile("CZ683555118-11.p12")
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken.p12"), []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var keystoreErrs KeystoreErrors
+	if err := ks.Reload(); !errors.As(err, &keystoreErrs) || len(keystoreErrs) != 1 {
+		t.Errorf("expected error for broken.p12, got %v", err)
+	}
+	if _, err := ks.Signer("CZ683555118", 11); err != nil {
+		t.Errorf("signer of reloaded file not found: %v", err)
+	}
+	if _, err := ks.Signer("CZ683555118", 12); !errors.Is(err, ErrNoSigner) {
+		t.Errorf("signer for establishment 11 used for 12: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "CZ00000019.p12")); err != nil {
+		t.Fatal(err)
+	}
+	_ = ks.Reload()
+	if _, err := ks.Signer("CZ00000019", 273); !errors.Is(err, ErrNoSigner) {
+		t.Errorf("signer of removed file still used: %v", err)
+	}
+}
+
+func TestDispatcher_WithKeystore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ca := newTestCA(t)
+	signers := map[string]*Signer{
+		"CZ00000019":  ca.newSigner(t, "CZ00000019"),
+		"CZ683555118": ca.newSigner(t, "CZ683555118"),
+	}
+	registry := NewRegistry()
+	for _, signer := range signers {
+		if err := registry.Add(signer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := NewDispatcher(Service(srv.URL), nil, WithKeystore(registry), WithResponseRoots(ca.pool()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for dic, signer := range signers {
+		outcome := d.Submit(context.Background(), Receipt{
+			UuidZpravy:   uuid.Must(uuid.NewV4()).String(),
+			PrvniZaslani: true,
+			DicPopl:      dic,
+			IdProvoz:     273,
+			IdPokl:       "/5546/RO24",
+			PoradCis:     "0/6460/ZQ42",
+			DatTrzby:     time.Now(),
+		})
+		if !outcome.Offline() {
+			t.Fatalf("expected offline outcome, got %v", outcome.Err)
+		}
+		pkp, err := NewPkp(outcome.Trzba, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outcome.Pkp() != pkp.Value {
+			t.Errorf("receipt of %s not signed by its certificate", dic)
+		}
+	}
+
+	outcome := d.Submit(context.Background(), Receipt{DicPopl: "CZ1212121218", IdProvoz: 273})
+	if !errors.Is(outcome.Err, ErrNoSigner) {
+		t.Errorf("expected ErrNoSigner, got %v", outcome.Err)
+	}
+}
+
+func TestDirKeystore_Rotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eet-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	copyFile := func(src, name string) {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "keystore", src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the expired certificate sorts last
+	copyFile("CZ00000019.p12", "renewed-2024.p12")
+	copyFile("expired/CZ00000019.p12", "zz-old.p12")
+
+	ks, err := NewDirKeystore(dir, Password("eet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ks.Signer("CZ00000019", 273)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.CheckValidity(time.Now()); err != nil {
+		t.Errorf("expired certificate preferred: %v", err)
+	}
+	if _, err := ks.Signer("CZ00000019", 2024); err != nil {
+		t.Errorf("signer of renewed-2024.p12 not used for all establishments: %v", err)
+	}
+
+	copyFile("CZ683555118-11.p12", "CZ00000019-11.p12")
+	var keystoreErrs KeystoreErrors
+	if err := ks.Reload(); !errors.As(err, &keystoreErrs) || len(keystoreErrs) != 1 {
+		t.Errorf("expected error for file name not matching the certificate, got %v", err)
+	}
+	if s, err := ks.Signer("CZ683555118", 11); err == nil {
+		t.Errorf("signer %s of misnamed file used", s.Subject())
+	}
+}
+
+func TestKeystoreName(t *testing.T) {
+	tests := []struct {
+		name     string
+		dic      CZDICType
+		idProvoz IdProvozType
+	}{
+		{"CZ00000019.p12", "", 0},
+		{"CZ00000019-11.p12", "CZ00000019", 11},
+		{"CZ683555118-273.pfx", "CZ683555118", 273},
+		{"company-2024.p12", "", 0},
+		{"CZ00000019-old.p12", "", 0},
+		{"CZ00000019-0.p12", "", 0},
+	}
+	for _, tt := range tests {
+		dic, idProvoz := keystoreName(tt.name)
+		if dic != tt.dic || idProvoz != tt.idProvoz {
+			t.Errorf("keystoreName(%q) = %q, %d, want %q, %d", tt.name, dic, idProvoz, tt.dic, tt.idProvoz)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package eet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metrics receives measurements of a Dispatcher, e.g. a Collector.
+// Methods are called synchronously and may be called concurrently.
+type Metrics interface {
+	// ObserveExchange is called for every attempt to send a message.
+	ObserveExchange(Exchange)
+	// ObserveOutcome is called for every submitted or resent receipt.
+	ObserveOutcome(Outcome)
+}
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms of Collector.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5}
+
+// Collector implements Metrics counting receipts, attempts, rejections by error code
+// and warnings by code, with latency histograms of signing, network and
+// decoding. It serves them over HTTP in the Prometheus text exposition format:
+//
+//	collector := eet.NewCollector()
+//	d, err := eet.NewDispatcher(service, signer, eet.WithMetrics(collector))
+//	http.Handle("/metrics", collector)
+type Collector struct {
+	mu       sync.Mutex
+	receipts *counterVec
+	attempts *counterVec
+	rejected *counterVec
+	warnings *counterVec
+	duration *histogram
+	signing  *histogram
+	network  *histogram
+	decoding *histogram
+}
+
+// NewCollector creates a Collector with DefaultBuckets.
+func NewCollector() *Collector {
+	return &Collector{
+		receipts: newCounterVec("eet_receipts_total", "Submitted and resent receipts by result: confirmed, rejected, offline (issued with PKP and BKP) or failed.", "result"),
+		attempts: newCounterVec("eet_attempts_total", "Attempts to send a message by result: accepted, rejected, timeout, http_error or error.", "result"),
+		rejected: newCounterVec("eet_rejected_total", "Messages rejected by the EET server by error code.", "code"),
+		warnings: newCounterVec("eet_warnings_total", "Warnings of accepted messages by code.", "code"),
+		duration: newHistogram("eet_attempt_duration_seconds", "Duration of attempts to send a message."),
+		signing:  newHistogram("eet_signing_duration_seconds", "Duration of signing the SOAP envelope."),
+		network:  newHistogram("eet_network_duration_seconds", "Duration of the HTTP round trip."),
+		decoding: newHistogram("eet_decoding_duration_seconds", "Duration of verifying and decoding the response."),
+	}
+}
+
+func (c *Collector) ObserveExchange(ex Exchange) {
+	result := "accepted"
+	var chyba *Chyba
+	var timeoutErr *TimeoutError
+	var httpErr *HTTPError
+	switch {
+	case ex.Err == nil:
+	case errors.As(ex.Err, &chyba):
+		result = "rejected"
+	case errors.As(ex.Err, &timeoutErr):
+		result = "timeout"
+	case errors.As(ex.Err, &httpErr):
+		result = "http_error"
+	default:
+		result = "error"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts.inc(result)
+	if chyba != nil {
+		c.rejected.inc(strconv.Itoa(int(chyba.Kod)))
+	}
+	if ex.Response != nil {
+		for _, w := range ex.Response.Warnings() {
+			c.warnings.inc(strconv.Itoa(int(w.Code)))
+		}
+	}
+	c.duration.observe(ex.Duration)
+	if ex.RequestBody != nil {
+		c.signing.observe(ex.Signing)
+	}
+	if ex.Network > 0 {
+		c.network.observe(ex.Network)
+	}
+	if ex.ResponseBody != nil {
+		c.decoding.observe(ex.Decoding)
+	}
+}
+
+func (c *Collector) ObserveOutcome(o Outcome) {
+	result := "failed"
+	switch {
+	case o.Confirmed():
+		result = "confirmed"
+	case o.Rejected():
+		result = "rejected"
+	case o.Offline():
+		result = "offline"
+	}
+	c.mu.Lock()
+	c.receipts.inc(result)
+	c.mu.Unlock()
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	cw := countingWriter{w: bufio.NewWriter(w)}
+	c.mu.Lock()
+	for _, v := range []*counterVec{c.receipts, c.attempts, c.rejected, c.warnings} {
+		v.write(&cw)
+	}
+	for _, h := range []*histogram{c.duration, c.signing, c.network, c.decoding} {
+		h.write(&cw)
+	}
+	c.mu.Unlock()
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
+
+type counterVec struct {
+	name   string
+	help   string
+	label  string
+	values map[string]uint64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, values: make(map[string]uint64)}
+}
+
+func (v *counterVec) inc(value string) {
+	v.values[value]++
+}
+
+func (v *counterVec) write(w *countingWriter) {
+	w.printf("# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name)
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.printf("%s{%s=%q} %d\n", v.name, v.label, k, v.values[k])
+	}
+}
+
+type histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(name, help string) *histogram {
+	return &histogram{name: name, help: help, buckets: DefaultBuckets, counts: make([]uint64, len(DefaultBuckets))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	for i, upper := range h.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (h *histogram) write(w *countingWriter) {
+	w.printf("# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for i, upper := range h.buckets {
+		w.printf("%s_bucket{le=\"%s\"} %d\n", h.name, strconv.FormatFloat(upper, 'g', -1, 64), h.counts[i])
+	}
+	w.printf("%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	w.printf("%s_sum %s\n", h.name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	w.printf("%s_count %d\n", h.name, h.count)
+}
+
+// countingWriter keeps the first error and the number of written bytes.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
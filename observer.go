@@ -0,0 +1,151 @@
+package eet
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exchange records a single attempt to send a message to the EET server.
+type Exchange struct {
+	Trzba Trzba
+	// Attempt is 1 for the first attempt, retries by RetryPolicy count up.
+	Attempt int
+	// RequestBody is the signed SOAP envelope, nil if it was not created.
+	RequestBody []byte
+	// ResponseBody is the raw response, nil if none was received.
+	ResponseBody []byte
+	// StatusCode is the HTTP status of the response, zero if none was received.
+	StatusCode int
+
+	Start time.Time
+	// Duration is the time of the whole attempt, which consists of Signing
+	// the envelope, the Network round trip and Decoding the response.
+	Duration time.Duration
+	Signing  time.Duration
+	Network  time.Duration
+	Decoding time.Duration
+
+	// Response and Err are the result of the attempt.
+	Response *Response
+	Err      error
+}
+
+// Observer receives every Exchange of a Dispatcher, e.g. to keep a record of
+// sent messages. Observe is called synchronously and may be called
+// concurrently, so it should return fast.
+type Observer interface {
+	Observe(Exchange)
+}
+
+// ObserverFunc adapts a function to Observer.
+type ObserverFunc func(Exchange)
+
+func (f ObserverFunc) Observe(ex Exchange) {
+	f(ex)
+}
+
+var (
+	redactTokenPattern = regexp.MustCompile(`(<(?:[\w-]+:)?BinarySecurityToken\b[^>]*>)[^<]*`)
+	redactPkpPattern   = regexp.MustCompile(`(<(?:[\w-]+:)?pkp\b[^>]*>)[^<]*`)
+)
+
+// Redact replaces the certificate and PKP in a SOAP message by REDACTED.
+func Redact(message []byte) []byte {
+	message = redactTokenPattern.ReplaceAll(message, []byte("${1}REDACTED"))
+	return redactPkpPattern.ReplaceAll(message, []byte("${1}REDACTED"))
+}
+
+// LogObserver is an Observer writing a line of key=value pairs per Exchange, e.g.
+//
+//	eet: uuid_zpravy=b3a0... dic_popl=CZ00000019 porad_cis=1 attempt=1 status=200 fik=... duration=85ms
+type LogObserver struct {
+	logger   *log.Logger
+	messages bool
+	redact   bool
+}
+
+// LogOption configures a LogObserver created by NewLogObserver.
+type LogOption func(*LogObserver)
+
+// LogMessages adds the request and response XML to every line.
+func LogMessages() LogOption {
+	return func(o *LogObserver) {
+		o.messages = true
+	}
+}
+
+// LogRedacted makes the logged messages Redact-ed of the certificate and PKP.
+func LogRedacted() LogOption {
+	return func(o *LogObserver) {
+		o.redact = true
+	}
+}
+
+// NewLogObserver creates a LogObserver writing to logger, the standard logger when nil.
+func NewLogObserver(logger *log.Logger, opts ...LogOption) *LogObserver {
+	if logger == nil {
+		logger = log.New(log.Writer(), "", log.LstdFlags)
+	}
+	o := LogObserver{logger: logger}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &o
+}
+
+func (o *LogObserver) Observe(ex Exchange) {
+	var buf bytes.Buffer
+	buf.WriteString("eet:")
+	kv := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		buf.WriteString(" " + key + "=" + value)
+	}
+
+	kv("uuid_zpravy", string(ex.Trzba.Hlavicka.UuidZpravy))
+	kv("dic_popl", string(ex.Trzba.Data.DicPopl))
+	if ex.Trzba.Data.IdProvoz != 0 {
+		kv("id_provoz", strconv.Itoa(int(ex.Trzba.Data.IdProvoz)))
+	}
+	kv("id_pokl", string(ex.Trzba.Data.IdPokl))
+	kv("porad_cis", string(ex.Trzba.Data.PoradCis))
+	kv("prvni_zaslani", strconv.FormatBool(ex.Trzba.Hlavicka.PrvniZaslani))
+	if ex.Trzba.Hlavicka.Overeni {
+		kv("overeni", "true")
+	}
+	kv("attempt", strconv.Itoa(ex.Attempt))
+	if ex.StatusCode != 0 {
+		kv("status", strconv.Itoa(ex.StatusCode))
+	}
+	if ex.Response != nil {
+		kv("fik", ex.Response.Fik)
+		for _, w := range ex.Response.Warnings() {
+			kv("warning", strconv.Itoa(int(w.Code)))
+		}
+	}
+	kv("bkp", ex.Trzba.KontrolniKody.Bkp.Value)
+	kv("duration", ex.Duration.String())
+	kv("signing", ex.Signing.String())
+	kv("network", ex.Network.String())
+	kv("decoding", ex.Decoding.String())
+	if ex.Err != nil {
+		kv("error", ex.Err.Error())
+	}
+	if o.messages {
+		request, response := ex.RequestBody, ex.ResponseBody
+		if o.redact {
+			request, response = Redact(request), Redact(response)
+		}
+		kv("request", string(request))
+		kv("response", string(response))
+	}
+	o.logger.Print(buf.String())
+}
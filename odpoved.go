@@ -6,24 +6,26 @@ import (
 	"time"
 )
 
+// SOAPEnvelopeResponse is the response of the EET server. Only the Body
+// returned by VerifySignature is signed, decode Odpoved from it.
 type SOAPEnvelopeResponse struct {
-	XMLName xml.Name `xml:"Envelope"`
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
 	Body    struct {
-		XMLName xml.Name `xml:"Body"`
+		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
 		Odpoved Odpoved
 	}
 }
 
 type Odpoved struct {
-	XMLName   xml.Name        `xml:"Odpoved"`
-	Hlavicka  OdpovedHlavicka `xml:"Hlavicka"`
-	Potvrzeni *Potvrzeni      `xml:"Potvrzeni"`
-	Chyba     *Chyba          `xml:"Chyba"`
-	Varovani  []Varovani      `xml:"Varovani"`
+	XMLName   xml.Name        `xml:"http://fs.mfcr.cz/eet/schema/v3 Odpoved"`
+	Hlavicka  OdpovedHlavicka `xml:"http://fs.mfcr.cz/eet/schema/v3 Hlavicka"`
+	Potvrzeni *Potvrzeni      `xml:"http://fs.mfcr.cz/eet/schema/v3 Potvrzeni"`
+	Chyba     *Chyba          `xml:"http://fs.mfcr.cz/eet/schema/v3 Chyba"`
+	Varovani  []Varovani      `xml:"http://fs.mfcr.cz/eet/schema/v3 Varovani"`
 }
 
 type OdpovedHlavicka struct {
-	XMLName    xml.Name  `xml:"Hlavicka"`
+	XMLName    xml.Name  `xml:"http://fs.mfcr.cz/eet/schema/v3 Hlavicka"`
 	UuidZpravy string    `xml:"uuid_zpravy,attr"`
 	DatPrij    time.Time `xml:"dat_prij,attr"`
 	DatOdmit   time.Time `xml:"dat_odmit,attr"`
@@ -31,24 +33,47 @@ type OdpovedHlavicka struct {
 }
 
 type Potvrzeni struct {
-	XMLName xml.Name `xml:"Potvrzeni"`
+	XMLName xml.Name `xml:"http://fs.mfcr.cz/eet/schema/v3 Potvrzeni"`
 	Fik     string   `xml:"fik,attr"`
 	Test    bool     `xml:"test,attr"`
 }
 
 type Chyba struct {
-	XMLName xml.Name `xml:"Chyba"`
-	Kod     int      `xml:"kod,attr"`
-	Test    bool     `xml:"test,attr"`
-	Chyba   string   `xml:",chardata"`
+	XMLName xml.Name  `xml:"http://fs.mfcr.cz/eet/schema/v3 Chyba"`
+	Kod     ErrorCode `xml:"kod,attr"`
+	Test    bool      `xml:"test,attr"`
+	Chyba   string    `xml:",chardata"`
+	// DatOdmit is the rejection time from the response header.
+	DatOdmit time.Time `xml:"-"`
 }
 
 func (ch Chyba) Error() string {
 	return fmt.Sprintf("%d %s", ch.Kod, ch.Chyba)
 }
 
+// Is reports whether target is a Chyba with the same code, so errors.Is(err, ErrTemporary) works.
+func (ch Chyba) Is(target error) bool {
+	switch t := target.(type) {
+	case *Chyba:
+		return t != nil && t.Kod == ch.Kod
+	case Chyba:
+		return t.Kod == ch.Kod
+	}
+	return false
+}
+
+// Temporary reports whether the error is a temporary technical error of the server.
+func (ch Chyba) Temporary() bool {
+	return ch.Kod == CodeTemporaryError
+}
+
+// Message returns the English description of the error code.
+func (ch Chyba) Message() string {
+	return ch.Kod.String()
+}
+
 type Varovani struct {
-	XMLName  xml.Name `xml:"Varovani"`
-	KodVarov int      `xml:"kod_varov,attr"`
-	Varovani string   `xml:",chardata"`
+	XMLName  xml.Name    `xml:"http://fs.mfcr.cz/eet/schema/v3 Varovani"`
+	KodVarov WarningCode `xml:"kod_varov,attr"`
+	Varovani string      `xml:",chardata"`
 }
@@ -0,0 +1,154 @@
+package eet
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the timeout of the HTTP client created by NewDispatcher.
+const DefaultTimeout = 5 * time.Second
+
+// Option configures a Dispatcher created by NewDispatcher.
+type Option func(*Dispatcher)
+
+// WithHTTPClient makes the Dispatcher send requests with client,
+// e.g. one with a custom http.RoundTripper for proxies or tracing.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithTimeout overrides the timeout of the HTTP client, DefaultTimeout is used otherwise.
+// Zero means no timeout, deadlines are then left to the context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.timeout = &timeout
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the transport, e.g. to pin
+// RootCAs for pg.eet.cz or prod.eet.cz. Combined with WithHTTPClient,
+// the client's transport has to be an *http.Transport.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(d *Dispatcher) {
+		d.tlsConfig = config
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests.
+func WithUserAgent(userAgent string) Option {
+	return func(d *Dispatcher) {
+		d.userAgent = userAgent
+	}
+}
+
+// WithResponseRoots sets the CA certificates the signature of EET responses
+// has to chain to, e.g. the pool loaded by LoadCertPool from
+// EET_CA1_Playground-ca.crt. The option is required: the EET CA of the
+// Financial Administration is not among the usual system roots, and
+// responses which cannot be verified are never trusted.
+func WithResponseRoots(roots *x509.CertPool) Option {
+	return func(d *Dispatcher) {
+		d.roots = roots
+	}
+}
+
+// WithWarningHandler registers fn called whenever a response carries warnings,
+// e.g. to raise back-office alerts. fn is called before the send call returns.
+func WithWarningHandler(fn func(Trzba, []Warning)) Option {
+	return func(d *Dispatcher) {
+		d.onWarnings = fn
+	}
+}
+
+// WithRetryPolicy makes the Dispatcher resend messages failed with
+// a retryable error according to policy, e.g. DefaultRetryPolicy.
+// Messages are not retried by default.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(d *Dispatcher) {
+		d.retryPolicy = policy
+	}
+}
+
+// WithSequencer makes the Dispatcher allocate porad_cis of receipts
+// submitted without it from s, e.g. a FileSequencer.
+func WithSequencer(s Sequencer) Option {
+	return func(d *Dispatcher) {
+		d.sequencer = s
+	}
+}
+
+// WithObserver registers o receiving every Exchange with the EET server,
+// including the signed request and the raw response, e.g. a LogObserver.
+func WithObserver(o Observer) Option {
+	return func(d *Dispatcher) {
+		d.observer = o
+	}
+}
+
+// WithMetrics makes the Dispatcher report its measurements to m, e.g. a Collector.
+func WithMetrics(m Metrics) Option {
+	return func(d *Dispatcher) {
+		d.metrics = m
+	}
+}
+
+// WithKeystore makes the Dispatcher sign each receipt by the signer of ks
+// for its dic_popl and id_provoz, e.g. a DirKeystore, so receipts of many
+// taxpayers can be sent by one Dispatcher. The signer passed to NewDispatcher,
+// if any, is used for taxpayers missing in ks.
+func WithKeystore(ks Keystore) Option {
+	return func(d *Dispatcher) {
+		d.keystore = ks
+	}
+}
+
+// ExpiryWarningInterval is the minimal interval between two calls of the
+// hook registered by WithExpiryWarning for the same certificate.
+const ExpiryWarningInterval = 24 * time.Hour
+
+// WithExpiryWarning registers fn called when a receipt is about to be signed
+// by a certificate expiring within the given duration, e.g. 30 days, so it can
+// be renewed in time. fn is called at most once per ExpiryWarningInterval.
+func WithExpiryWarning(within time.Duration, fn func(cert *x509.Certificate)) Option {
+	return func(d *Dispatcher) {
+		d.expiry = &expiryWarning{
+			within: within,
+			fn:     fn,
+			warned: make(map[[sha256.Size]byte]time.Time),
+		}
+	}
+}
+
+type expiryWarning struct {
+	within time.Duration
+	fn     func(*x509.Certificate)
+
+	mu     sync.Mutex
+	warned map[[sha256.Size]byte]time.Time
+}
+
+// check calls fn if cert expires within the duration and it was not
+// called for cert during the last ExpiryWarningInterval.
+func (w *expiryWarning) check(cert *x509.Certificate, now time.Time) {
+	if cert.NotAfter.Sub(now) > w.within {
+		return
+	}
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	w.mu.Lock()
+	last, ok := w.warned[fingerprint]
+	if ok && now.Sub(last) < ExpiryWarningInterval {
+		w.mu.Unlock()
+		return
+	}
+	w.warned[fingerprint] = now
+	w.mu.Unlock()
+
+	w.fn(cert)
+}
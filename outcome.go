@@ -0,0 +1,47 @@
+package eet
+
+import "errors"
+
+// Outcome is the result of submitting a receipt. Trzba holds the signed
+// message including KontrolniKody as soon as signing succeeded, whether or
+// not the EET server confirmed it. When the receipt is not confirmed it can
+// be printed with PKP and BKP instead of FIK, and Trzba can be persisted
+// (e.g. with xml.Marshal) and passed to Dispatcher.Resend later.
+type Outcome struct {
+	Trzba    Trzba
+	Response *Response
+	Err      error
+}
+
+// Confirmed reports whether the server accepted the message: it returned FIK,
+// or for a message sent in verification mode, code 0.
+func (o Outcome) Confirmed() bool {
+	return o.Err == nil && o.Response != nil
+}
+
+// Offline reports whether the receipt was signed but not confirmed,
+// so it has to be issued in offline mode and resent within 48 hours.
+// Rejected messages and messages sent in verification mode are never offline.
+func (o Outcome) Offline() bool {
+	return !o.Confirmed() && o.Trzba.KontrolniKody.Pkp.Value != "" && !o.Trzba.Hlavicka.Overeni && !o.Rejected()
+}
+
+// Rejected reports whether the server refused the message with Chyba other
+// than the temporary error, or the message violates EETSchema and was not
+// sent at all. Sending it again would not succeed, the receipt must be
+// corrected and submitted as a new message.
+func (o Outcome) Rejected() bool {
+	var ch *Chyba
+	var schemaErrs SchemaErrors
+	return errors.As(o.Err, &ch) && !ch.Temporary() || errors.As(o.Err, &schemaErrs)
+}
+
+// Pkp returns the base64 encoded PKP code, empty if the receipt could not be signed.
+func (o Outcome) Pkp() string {
+	return o.Trzba.KontrolniKody.Pkp.Value
+}
+
+// Bkp returns the BKP code, empty if the receipt could not be signed.
+func (o Outcome) Bkp() string {
+	return o.Trzba.KontrolniKody.Bkp.Value
+}
@@ -0,0 +1,42 @@
+package eet
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestOutcome(t *testing.T) {
+	signed := Trzba{KontrolniKody: KontrolniKody{Pkp: Pkp{Value: "cGtw"}, Bkp: Bkp{Value: "bkp"}}}
+	overeni := signed
+	overeni.Hlavicka.Overeni = true
+
+	tests := []struct {
+		name      string
+		outcome   Outcome
+		confirmed bool
+		offline   bool
+		rejected  bool
+	}{
+		{"confirmed", Outcome{Trzba: signed, Response: &Response{Fik: "fik"}}, true, false, false},
+		{"not signed", Outcome{Err: errors.New("invalid receipt")}, false, false, false},
+		{"timeout", Outcome{Trzba: signed, Err: &TimeoutError{Err: errors.New("deadline")}}, false, true, false},
+		{"temporary chyba", Outcome{Trzba: signed, Err: &Chyba{Kod: CodeTemporaryError}}, false, true, false},
+		{"rejected", Outcome{Trzba: signed, Err: fmt.Errorf("sending: %w", &Chyba{Kod: CodeSchemaViolation})}, false, false, true},
+		{"schema violation", Outcome{Trzba: signed, Err: SchemaErrors{{Field: "Trzba/Data@id_pokl", Constraint: "too long"}}}, false, false, true},
+		{"verification mode", Outcome{Trzba: overeni, Err: &HTTPError{StatusCode: 503}}, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.outcome.Confirmed(); got != tt.confirmed {
+				t.Errorf("Confirmed() = %v, want %v", got, tt.confirmed)
+			}
+			if got := tt.outcome.Offline(); got != tt.offline {
+				t.Errorf("Offline() = %v, want %v", got, tt.offline)
+			}
+			if got := tt.outcome.Rejected(); got != tt.rejected {
+				t.Errorf("Rejected() = %v, want %v", got, tt.rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package eet
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	// ErrIncorrectPassword is returned when PKCS#12 data cannot be decrypted
+	// or its integrity verified with the given password.
+	ErrIncorrectPassword = errors.New("eet: incorrect PKCS#12 password")
+	// ErrUnsupportedPKCS12 is returned for PKCS#12 data using a structure or
+	// an algorithm which is not supported.
+	ErrUnsupportedPKCS12 = errors.New("eet: unsupported PKCS#12 format")
+)
+
+// errLegacyPKCS12 marks algorithms left to golang.org/x/crypto/pkcs12.
+var errLegacyPKCS12 = errors.New("legacy PKCS#12 algorithm")
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+
+	oidPBEWithSHAAnd128BitRC2CBC  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 5}
+	oidPBEWithSHAAnd40BitRC2CBC   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBEWithSHAAnd3KeyTripleDES = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBES2                      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	Prf        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// unmarshalDER decodes in to out, rejecting trailing data.
+func unmarshalDER(in []byte, out interface{}) error {
+	rest, err := asn1.Unmarshal(in, out)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("trailing data after ASN.1 structure")
+	}
+	return nil
+}
+
+// decodePKCS12 decodes the private keys and all certificates of PKCS#12
+// data protected by PBES2 (PBKDF2 with AES or 3DES) or by the PKCS#12 PBE
+// with 3DES, with a SHA-1 or SHA-2 MAC. Data using RC2 is reported by
+// errLegacyPKCS12.
+func decodePKCS12(pfxData []byte, password string) ([]crypto.Signer, []*x509.Certificate, error) {
+	var pfx pfxPdu
+	if err := unmarshalDER(pfxData, &pfx); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+	}
+	if pfx.Version != 3 {
+		return nil, nil, fmt.Errorf("%w: version %d", ErrUnsupportedPKCS12, pfx.Version)
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, nil, fmt.Errorf("%w: public-key integrity mode", ErrUnsupportedPKCS12)
+	}
+	var authSafe []byte
+	if err := unmarshalDER(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+	}
+
+	bmpPassword, err := bmpString(password)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) > 0 {
+		err := verifyMAC(pfx.MacData, authSafe, bmpPassword)
+		if err == ErrIncorrectPassword && password == "" {
+			// some implementations use no bytes at all for the empty password
+			bmpPassword = nil
+			err = verifyMAC(pfx.MacData, authSafe, bmpPassword)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	p := pkcs12Password{bmp: bmpPassword, utf8: []byte(password)}
+
+	var contents []contentInfo
+	if err := unmarshalDER(authSafe, &contents); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+	}
+	var bags []safeBag
+	for _, ci := range contents {
+		var data []byte
+		switch {
+		case ci.ContentType.Equal(oidDataContentType):
+			if err := unmarshalDER(ci.Content.Bytes, &data); err != nil {
+				return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+			}
+		case ci.ContentType.Equal(oidEncryptedDataContentType):
+			var ed encryptedData
+			if err := unmarshalDER(ci.Content.Bytes, &ed); err != nil {
+				return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+			}
+			info := ed.EncryptedContentInfo
+			if data, err = decryptPKCS12(info.ContentEncryptionAlgorithm, info.EncryptedContent, p); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("%w: content type %s", ErrUnsupportedPKCS12, ci.ContentType)
+		}
+
+		var safeContents []safeBag
+		if err := unmarshalDER(data, &safeContents); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+		}
+		bags = append(bags, safeContents...)
+	}
+
+	var (
+		keys  []crypto.Signer
+		certs []*x509.Certificate
+	)
+	for _, bag := range bags {
+		switch {
+		case bag.ID.Equal(oidCertBag):
+			var cb certBag
+			if err := unmarshalDER(bag.Value.Bytes, &cb); err != nil {
+				return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+			}
+			if !cb.ID.Equal(oidCertTypeX509) {
+				continue
+			}
+			cert, err := x509.ParseCertificate(cb.Data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parsing certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+			der := bag.Value.Bytes
+			if bag.ID.Equal(oidPKCS8ShroudedKeyBag) {
+				var info encryptedPrivateKeyInfo
+				if err := unmarshalDER(bag.Value.Bytes, &info); err != nil {
+					return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+				}
+				if der, err = decryptPKCS12(info.Algorithm, info.EncryptedData, p); err != nil {
+					return nil, nil, err
+				}
+			}
+			parsed, err := x509.ParsePKCS8PrivateKey(der)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parsing private key: %w", err)
+			}
+			key, ok := parsed.(crypto.Signer)
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: private key type %T", ErrUnsupportedPKCS12, parsed)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, certs, nil
+}
+
+// pkcs12Password holds the password encoded for PKCS#12 PBE and MAC keys,
+// which use BMPString, and for PBES2, which uses UTF-8.
+type pkcs12Password struct {
+	bmp  []byte
+	utf8 []byte
+}
+
+// bmpString encodes s in UCS-2 with the trailing zero character.
+func bmpString(s string) ([]byte, error) {
+	var b []byte
+	for _, r := range s {
+		if r > 0xffff || utf16.IsSurrogate(r) {
+			return nil, fmt.Errorf("%w: password character %q outside BMP", ErrUnsupportedPKCS12, r)
+		}
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return append(b, 0, 0), nil
+}
+
+// macHash returns the hash function of a MAC or HMAC algorithm with its
+// output and block sizes in bytes.
+func macHash(oid asn1.ObjectIdentifier) (func() hash.Hash, int, int, error) {
+	switch {
+	case oid.Equal(oidSHA1), oid.Equal(oidHMACWithSHA1):
+		return sha1.New, sha1.Size, sha1.BlockSize, nil
+	case oid.Equal(oidSHA256), oid.Equal(oidHMACWithSHA256):
+		return sha256.New, sha256.Size, sha256.BlockSize, nil
+	case oid.Equal(oidSHA384), oid.Equal(oidHMACWithSHA384):
+		return sha512.New384, sha512.Size384, sha512.BlockSize, nil
+	case oid.Equal(oidSHA512), oid.Equal(oidHMACWithSHA512):
+		return sha512.New, sha512.Size, sha512.BlockSize, nil
+	}
+	return nil, 0, 0, fmt.Errorf("%w: digest algorithm %s", ErrUnsupportedPKCS12, oid)
+}
+
+func verifyMAC(md macData, message, password []byte) error {
+	h, u, v, err := macHash(md.Mac.Algorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	key := pkcs12KDF(h, v, md.MacSalt, password, md.Iterations, 3, u)
+	mac := hmac.New(h, key)
+	mac.Write(message)
+	if !hmac.Equal(mac.Sum(nil), md.Mac.Digest) {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+// decryptPKCS12 decrypts data encrypted with the password based algorithm.
+func decryptPKCS12(algorithm pkix.AlgorithmIdentifier, data []byte, p pkcs12Password) ([]byte, error) {
+	var (
+		block cipher.Block
+		iv    []byte
+		err   error
+	)
+	switch oid := algorithm.Algorithm; {
+	case oid.Equal(oidPBEWithSHAAnd3KeyTripleDES):
+		var params pbeParams
+		if err := unmarshalDER(algorithm.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+		}
+		key := pkcs12KDF(sha1.New, sha1.BlockSize, params.Salt, p.bmp, params.Iterations, 1, 24)
+		iv = pkcs12KDF(sha1.New, sha1.BlockSize, params.Salt, p.bmp, params.Iterations, 2, des.BlockSize)
+		if block, err = des.NewTripleDESCipher(key); err != nil {
+			return nil, err
+		}
+	case oid.Equal(oidPBES2):
+		if block, iv, err = pbes2Cipher(algorithm, p.utf8); err != nil {
+			return nil, err
+		}
+	case oid.Equal(oidPBEWithSHAAnd40BitRC2CBC), oid.Equal(oidPBEWithSHAAnd128BitRC2CBC):
+		return nil, errLegacyPKCS12
+	default:
+		return nil, fmt.Errorf("%w: encryption algorithm %s", ErrUnsupportedPKCS12, oid)
+	}
+
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("%w: encrypted data length %d", ErrUnsupportedPKCS12, len(data))
+	}
+	decrypted := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	// a wrong password shows up as invalid padding when there is no MAC
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > block.BlockSize() ||
+		!bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, ErrIncorrectPassword
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
+
+// pbes2Cipher derives the key of the PBES2 scheme by PBKDF2 and returns the
+// block cipher with its IV.
+func pbes2Cipher(algorithm pkix.AlgorithmIdentifier, password []byte) (cipher.Block, []byte, error) {
+	var params pbes2Params
+	if err := unmarshalDER(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, fmt.Errorf("%w: key derivation function %s", ErrUnsupportedPKCS12, params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if err := unmarshalDER(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+	}
+	prf := sha1.New
+	if len(kdf.Prf.Algorithm) > 0 {
+		var err error
+		if prf, _, _, err = macHash(kdf.Prf.Algorithm); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var (
+		keyLen   int
+		newBlock func([]byte) (cipher.Block, error)
+	)
+	switch oid := params.EncryptionScheme.Algorithm; {
+	case oid.Equal(oidAES128CBC):
+		keyLen, newBlock = 16, aes.NewCipher
+	case oid.Equal(oidAES192CBC):
+		keyLen, newBlock = 24, aes.NewCipher
+	case oid.Equal(oidAES256CBC):
+		keyLen, newBlock = 32, aes.NewCipher
+	case oid.Equal(oidDESEDE3CBC):
+		keyLen, newBlock = 24, des.NewTripleDESCipher
+	default:
+		return nil, nil, fmt.Errorf("%w: encryption scheme %s", ErrUnsupportedPKCS12, oid)
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != keyLen {
+		return nil, nil, fmt.Errorf("%w: key length %d", ErrUnsupportedPKCS12, kdf.KeyLength)
+	}
+	var iv []byte
+	if err := unmarshalDER(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnsupportedPKCS12, err)
+	}
+
+	key := pbkdf2.Key(password, kdf.Salt, kdf.Iterations, keyLen, prf)
+	block, err := newBlock(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, nil, fmt.Errorf("%w: IV length %d", ErrUnsupportedPKCS12, len(iv))
+	}
+	return block, iv, nil
+}
+
+// pkcs12KDF derives size bytes for the purpose id from the password as
+// specified in RFC 7292, appendix B.2. v is the block size of the hash function.
+func pkcs12KDF(h func() hash.Hash, v int, salt, password []byte, iterations int, id byte, size int) []byte {
+	fill := func(pattern []byte) []byte {
+		if len(pattern) == 0 {
+			return nil
+		}
+		n := v * ((len(pattern) + v - 1) / v)
+		return bytes.Repeat(pattern, (n+len(pattern)-1)/len(pattern))[:n]
+	}
+	d := bytes.Repeat([]byte{id}, v)
+	i := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < size {
+		hh := h()
+		hh.Write(d)
+		hh.Write(i)
+		a := hh.Sum(nil)
+		for j := 1; j < iterations; j++ {
+			hh.Reset()
+			hh.Write(a)
+			a = hh.Sum(a[:0])
+		}
+		out = append(out, a...)
+
+		// I_j = (I_j + B + 1) mod 2^v for every v-byte block of I
+		b := fill(a)[:v]
+		for j := 0; j < len(i); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(i[j+k]) + int(b[k]) + carry
+				i[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return out[:size]
+}
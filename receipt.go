@@ -3,119 +3,147 @@ package eet
 import (
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 )
 
 type Receipt struct {
 	UuidZpravy      string
 	PrvniZaslani    bool
+	Overeni         bool // verification mode, the receipt is checked but not registered
 	DicPopl         string
 	DicPoverujiciho string
 	IdProvoz        int
 	IdPokl          string
 	PoradCis        string
 	DatTrzby        time.Time
-	CelkTrzba       float64
-	ZaklNepodlDph   float64
-	ZaklDan1        float64
-	Dan1            float64
-	ZaklDan2        float64
-	Dan2            float64
-	ZaklDan3        float64
-	Dan3            float64
-	CestSluz        float64
-	PouzitZboz1     float64
-	PouzitZboz2     float64
-	PouzitZboz3     float64
-	UrcenoCerpZuct  float64
-	CerpZuct        float64
+	CelkTrzba       Castka
+	ZaklNepodlDph   Castka
+	ZaklDan1        Castka
+	Dan1            Castka
+	ZaklDan2        Castka
+	Dan2            Castka
+	ZaklDan3        Castka
+	Dan3            Castka
+	CestSluz        Castka
+	PouzitZboz1     Castka
+	PouzitZboz2     Castka
+	PouzitZboz3     Castka
+	UrcenoCerpZuct  Castka
+	CerpZuct        Castka
 	Rezim           Regime
 }
 
+// Trzba converts the receipt to the signed message. A random (version 4)
+// UuidZpravy is generated when empty.
 func (r Receipt) Trzba(signer *Signer) (Trzba, error) {
+	t, err := r.trzba()
+	if err != nil {
+		return Trzba{}, err
+	}
+	// KontrolniKody
+	pkp, err := NewPkp(t, signer)
+	if err != nil {
+		return Trzba{}, errors.Wrap(err, "Failed to create PKP")
+	}
+	t.KontrolniKody.Pkp = pkp
+
+	bkp, err := NewBkp(t.KontrolniKody.Pkp)
+	if err != nil {
+		return Trzba{}, errors.Wrap(err, "Failed to create BKP")
+	}
+	t.KontrolniKody.Bkp = bkp
+
+	return t, nil
+}
+
+// trzba converts the receipt to the message without KontrolniKody.
+func (r Receipt) trzba() (Trzba, error) {
 	var t Trzba
 	var err error
+	if r.UuidZpravy == "" {
+		if r.UuidZpravy, err = newUUID(); err != nil {
+			return Trzba{}, err
+		}
+	}
 	// Hlavicka
 	t.Hlavicka.DatOdesl = NewDateTimeType(time.Now())
 	t.Hlavicka.UuidZpravy, err = NewUUIDType(r.UuidZpravy)
 	if err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create UuidZpravy")
+		return Trzba{}, fieldError("uuid_zpravy", err)
 	}
 	t.Hlavicka.PrvniZaslani = r.PrvniZaslani
+	t.Hlavicka.Overeni = r.Overeni
 	// Data
 	if t.Data.DicPopl, err = NewCZDICType(r.DicPopl); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create DicPopl")
+		return Trzba{}, fieldError("dic_popl", err)
 	}
 	if t.Data.DicPoverujiciho, err = NewCZDICType(r.DicPoverujiciho); len(r.DicPoverujiciho) != 0 && err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create DicPoverujiciho")
+		return Trzba{}, fieldError("dic_poverujiciho", err)
 	}
 	if t.Data.IdProvoz, err = NewIdProvozType(r.IdProvoz); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create IdProvoz")
+		return Trzba{}, fieldError("id_provoz", err)
 	}
 	if t.Data.IdPokl, err = NewString20(r.IdPokl); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create IdPokl")
+		return Trzba{}, fieldError("id_pokl", err)
 	}
 	if t.Data.PoradCis, err = NewString25(r.PoradCis); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create PoradCis")
+		return Trzba{}, fieldError("porad_cis", err)
 	}
 	t.Data.DatTrzby = NewDateTimeType(r.DatTrzby)
 	if t.Data.CelkTrzba, err = NewCastkaType(r.CelkTrzba); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create CelkTrzba")
+		return Trzba{}, fieldError("celk_trzba", err)
 	}
 	if t.Data.ZaklNepodlDph, err = NewCastkaType(r.ZaklNepodlDph); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create ZaklNepodlDph")
+		return Trzba{}, fieldError("zakl_nepodl_dph", err)
 	}
 	if t.Data.ZaklDan1, err = NewCastkaType(r.ZaklDan1); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create ZaklDan1")
+		return Trzba{}, fieldError("zakl_dan1", err)
 	}
 	if t.Data.Dan1, err = NewCastkaType(r.Dan1); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create Dan1")
+		return Trzba{}, fieldError("dan1", err)
 	}
 	if t.Data.ZaklDan2, err = NewCastkaType(r.ZaklDan2); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create ZaklDan2")
+		return Trzba{}, fieldError("zakl_dan2", err)
 	}
 	if t.Data.Dan2, err = NewCastkaType(r.Dan2); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create Dan2")
+		return Trzba{}, fieldError("dan2", err)
 	}
 	if t.Data.ZaklDan3, err = NewCastkaType(r.ZaklDan3); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create ZaklDan3")
+		return Trzba{}, fieldError("zakl_dan3", err)
 	}
 	if t.Data.Dan3, err = NewCastkaType(r.Dan3); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create Dan3")
+		return Trzba{}, fieldError("dan3", err)
 	}
 	if t.Data.CestSluz, err = NewCastkaType(r.CestSluz); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create CestSluz")
+		return Trzba{}, fieldError("cest_sluz", err)
 	}
 	if t.Data.PouzitZboz1, err = NewCastkaType(r.PouzitZboz1); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create PouzitZboz1")
+		return Trzba{}, fieldError("pouzit_zboz1", err)
 	}
 	if t.Data.PouzitZboz2, err = NewCastkaType(r.PouzitZboz2); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create PouzitZboz2")
+		return Trzba{}, fieldError("pouzit_zboz2", err)
 	}
 	if t.Data.PouzitZboz3, err = NewCastkaType(r.PouzitZboz3); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create PouzitZboz3")
+		return Trzba{}, fieldError("pouzit_zboz3", err)
 	}
 	if t.Data.UrcenoCerpZuct, err = NewCastkaType(r.UrcenoCerpZuct); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create UrcenoCerpZuct")
+		return Trzba{}, fieldError("urceno_cerp_zuct", err)
 	}
 	if t.Data.CerpZuct, err = NewCastkaType(r.CerpZuct); err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create CerpZuct")
+		return Trzba{}, fieldError("cerp_zuct", err)
 	}
 	if r.Rezim == SimplifiedRegime {
 		t.Data.Rezim = ZjednodusenyRezim
 	}
-	// KontrolniKody
-	pkp, err := NewPkp(t, signer)
-	if err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create PKP")
-	}
-	t.KontrolniKody.Pkp = pkp
+	return t, nil
+}
 
-	bkp, err := NewBkp(t.KontrolniKody.Pkp)
+// newUUID returns a random (version 4) UUID for uuid_zpravy.
+func newUUID() (string, error) {
+	u, err := uuid.NewV4()
 	if err != nil {
-		return Trzba{}, errors.Wrap(err, "Failed to create BKP")
+		return "", errors.Wrap(err, "Failed to generate uuid_zpravy")
 	}
-	t.KontrolniKody.Bkp = bkp
-
-	return t, nil
+	return u.String(), nil
 }
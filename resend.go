@@ -0,0 +1,366 @@
+package eet
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResendDeadline is the period after the sale within which an unconfirmed receipt has to be sent again.
+const ResendDeadline = 48 * time.Hour
+
+// PendingTrzba is a signed Trzba waiting to be confirmed by the EET server.
+type PendingTrzba struct {
+	Trzba          Trzba     `xml:"Trzba"`
+	Queued         time.Time `xml:"queued,attr"`
+	Attempts       int       `xml:"attempts,attr"`
+	NextAttempt    time.Time `xml:"next_attempt,attr"`
+	DeadlineWarned bool      `xml:"deadline_warned,attr,omitempty"`
+	LastError      string    `xml:"LastError,omitempty"`
+}
+
+// Deadline returns the time until which the Trzba has to be confirmed,
+// ResendDeadline after its dat_trzby.
+func (p PendingTrzba) Deadline() time.Time {
+	datTrzby, err := time.Parse(time.RFC3339, string(p.Trzba.Data.DatTrzby))
+	if err != nil {
+		datTrzby = p.Queued
+	}
+	return datTrzby.Add(ResendDeadline)
+}
+
+// Store persists pending Trzba until they are confirmed. Items are keyed by UuidZpravy.
+type Store interface {
+	// Save inserts or replaces the item with the same UuidZpravy.
+	Save(p PendingTrzba) error
+	// Delete removes the item, deleting a missing item is not an error.
+	Delete(uuid UUIDType) error
+	// List returns all stored items.
+	List() ([]PendingTrzba, error)
+}
+
+// MemoryStore is a Store keeping items in memory, e.g. for tests.
+type MemoryStore struct {
+	mu    sync.Mutex
+	items map[UUIDType]PendingTrzba
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: map[UUIDType]PendingTrzba{}}
+}
+
+func (s *MemoryStore) Save(p PendingTrzba) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[p.Trzba.Hlavicka.UuidZpravy] = p
+	return nil
+}
+
+func (s *MemoryStore) Delete(uuid UUIDType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, uuid)
+	return nil
+}
+
+func (s *MemoryStore) List() ([]PendingTrzba, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make([]PendingTrzba, 0, len(s.items))
+	for _, p := range s.items {
+		items = append(items, p)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Queued.Before(items[j].Queued) })
+	return items, nil
+}
+
+// FileStore is a Store keeping every item as an XML file in a directory.
+// Files are replaced atomically, so a crash never leaves a partial item behind.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating directory %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(uuid UUIDType) string {
+	return filepath.Join(s.dir, string(uuid)+".xml")
+}
+
+func (s *FileStore) Save(p PendingTrzba) error {
+	if _, err := NewUUIDType(string(p.Trzba.Hlavicka.UuidZpravy)); err != nil {
+		return fmt.Errorf("saving pending trzba: %w", err)
+	}
+	data, err := xml.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling pending trzba: %w", err)
+	}
+	tmp, err := ioutil.TempFile(s.dir, ".pending-")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("syncing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(p.Trzba.Hlavicka.UuidZpravy)); err != nil {
+		return fmt.Errorf("renaming %s: %w", tmp.Name(), err)
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(uuid UUIDType) error {
+	if err := os.Remove(s.path(uuid)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing pending trzba %s: %w", uuid, err)
+	}
+	return nil
+}
+
+func (s *FileStore) List() ([]PendingTrzba, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory %s: %w", s.dir, err)
+	}
+	var items []PendingTrzba
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || filepath.Ext(f.Name()) != ".xml" {
+			continue
+		}
+		path := filepath.Join(s.dir, f.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading file %s: %w", path, err)
+		}
+		var p PendingTrzba
+		if err := xml.Unmarshal(data, &p); err != nil {
+			return nil, fmt.Errorf("unmarshaling %s: %w", path, err)
+		}
+		items = append(items, p)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Queued.Before(items[j].Queued) })
+	return items, nil
+}
+
+// Resender resends pending Trzba from a Store with exponential backoff until
+// they are confirmed. Resent messages keep their UuidZpravy, PKP and BKP, and
+// are sent with PrvniZaslani false and a fresh dat_odesl.
+type Resender struct {
+	dispatcher  *Dispatcher
+	store       Store
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	interval    time.Duration
+	warnBefore  time.Duration
+	onConfirmed func(PendingTrzba, *Response)
+	onRejected  func(PendingTrzba, error)
+	onDeadline  func(PendingTrzba)
+	now         func() time.Time
+}
+
+// ResenderOption configures a Resender created by NewResender.
+type ResenderOption func(*Resender)
+
+// WithBackoff sets the delay after the first failed attempt, doubled on every
+// further failure up to max. Defaults are 30 seconds and 30 minutes.
+func WithBackoff(min, max time.Duration) ResenderOption {
+	return func(r *Resender) {
+		r.minBackoff = min
+		r.maxBackoff = max
+	}
+}
+
+// WithPollInterval sets how often Run looks for due items, 10 seconds by default.
+func WithPollInterval(interval time.Duration) ResenderOption {
+	return func(r *Resender) {
+		r.interval = interval
+	}
+}
+
+// OnConfirmed registers fn called when a pending Trzba gets confirmed.
+func OnConfirmed(fn func(PendingTrzba, *Response)) ResenderOption {
+	return func(r *Resender) {
+		r.onConfirmed = fn
+	}
+}
+
+// OnRejected registers fn called when a pending Trzba was rejected, see
+// Outcome.Rejected, e.g. for a schema error reported by the server.
+// The Trzba is removed from the store before fn is called, the receipt has
+// to be corrected and submitted as a new message.
+func OnRejected(fn func(PendingTrzba, error)) ResenderOption {
+	return func(r *Resender) {
+		r.onRejected = fn
+	}
+}
+
+// OnDeadline registers fn called once for every pending Trzba which is still
+// not confirmed when less than before remains until its Deadline.
+func OnDeadline(before time.Duration, fn func(PendingTrzba)) ResenderOption {
+	return func(r *Resender) {
+		r.warnBefore = before
+		r.onDeadline = fn
+	}
+}
+
+// NewResender creates a Resender sending items of store with dispatcher.
+func NewResender(dispatcher *Dispatcher, store Store, opts ...ResenderOption) (*Resender, error) {
+	if dispatcher == nil {
+		return nil, errors.New("dispatcher is required")
+	}
+	if store == nil {
+		return nil, errors.New("store is required")
+	}
+	r := Resender{
+		dispatcher: dispatcher,
+		store:      store,
+		minBackoff: 30 * time.Second,
+		maxBackoff: 30 * time.Minute,
+		interval:   10 * time.Second,
+		warnBefore: 6 * time.Hour,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return &r, nil
+}
+
+// Enqueue stores the Trzba of an offline outcome. Confirmed outcomes and
+// outcomes without a signed Trzba are ignored.
+func (r *Resender) Enqueue(o Outcome) error {
+	if !o.Offline() {
+		return nil
+	}
+	now := r.now()
+	p := PendingTrzba{
+		Trzba:       o.Trzba,
+		Queued:      now,
+		Attempts:    1,
+		NextAttempt: now.Add(r.backoff(1)),
+	}
+	if o.Err != nil {
+		p.LastError = o.Err.Error()
+	}
+	return r.store.Save(p)
+}
+
+// Run calls Flush periodically until ctx is done or Flush fails, e.g. with
+// a configuration error. Pending items stay in the store in either case.
+func (r *Resender) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush resends all items whose next attempt is due. Items the server
+// rejected, see Outcome.Rejected, are removed from the store and reported to
+// the OnRejected hook. Items failed with any other error are kept and
+// rescheduled. Errors which are not retryable, e.g. an untrusted signature of
+// the response or a missing signer, are caused by the configuration rather
+// than by the receipt: all items are still tried, and the first such error is
+// returned once they are saved, as is an error of the store or of ctx.
+func (r *Resender) Flush(ctx context.Context) error {
+	items, err := r.store.List()
+	if err != nil {
+		return fmt.Errorf("listing pending trzba: %w", err)
+	}
+	var failed error
+	for _, p := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		now := r.now()
+		if r.onDeadline != nil && !p.DeadlineWarned && now.Add(r.warnBefore).After(p.Deadline()) {
+			p.DeadlineWarned = true
+			if err := r.store.Save(p); err != nil {
+				return fmt.Errorf("saving pending trzba: %w", err)
+			}
+			r.onDeadline(p)
+		}
+		if now.Before(p.NextAttempt) {
+			continue
+		}
+
+		outcome := r.dispatcher.Resend(ctx, p.Trzba)
+		if outcome.Confirmed() {
+			if err := r.store.Delete(p.Trzba.Hlavicka.UuidZpravy); err != nil {
+				return fmt.Errorf("deleting pending trzba: %w", err)
+			}
+			if r.onConfirmed != nil {
+				r.onConfirmed(p, outcome.Response)
+			}
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if errors.Is(outcome.Err, ErrInFlight) {
+			continue
+		}
+		if outcome.Rejected() {
+			if err := r.store.Delete(p.Trzba.Hlavicka.UuidZpravy); err != nil {
+				return fmt.Errorf("deleting pending trzba: %w", err)
+			}
+			if r.onRejected != nil {
+				r.onRejected(p, outcome.Err)
+			}
+			continue
+		}
+		p.Attempts++
+		p.NextAttempt = r.now().Add(r.backoff(p.Attempts))
+		if outcome.Err != nil {
+			p.LastError = outcome.Err.Error()
+		}
+		if err := r.store.Save(p); err != nil {
+			return fmt.Errorf("saving pending trzba: %w", err)
+		}
+		if failed == nil && !IsRetryable(outcome.Err) {
+			failed = fmt.Errorf("resending pending trzba %s: %w", p.Trzba.Hlavicka.UuidZpravy, outcome.Err)
+		}
+	}
+	return failed
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (r *Resender) backoff(attempts int) time.Duration {
+	delay := r.minBackoff
+	for i := 1; i < attempts && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,81 @@
+package eet
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestResender_Flush(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "eet-resend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := newTestCA(t)
+	d, err := NewDispatcher(Service(srv.URL), ca.newSigner(t, "CZ00000019"), WithResponseRoots(ca.pool()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warned []PendingTrzba
+	resender, err := NewResender(d, store,
+		WithBackoff(time.Minute, time.Hour),
+		OnDeadline(ResendDeadline, func(p PendingTrzba) { warned = append(warned, p) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outcome := d.Submit(context.Background(), Receipt{
+		UuidZpravy:   uuid.Must(uuid.NewV4()).String(),
+		PrvniZaslani: true,
+		DicPopl:      "CZ00000019",
+		IdProvoz:     273,
+		IdPokl:       "/5546/RO24",
+		PoradCis:     "0/6460/ZQ42",
+		DatTrzby:     time.Now(),
+	})
+	if err := resender.Enqueue(outcome); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Add(2 * time.Minute)
+	resender.now = func() time.Time { return now }
+	if err := resender.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("expected 1 pending item, got %d", len(items))
+	}
+	p := items[0]
+	if p.Attempts != 2 || !p.NextAttempt.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("unexpected rescheduling: attempts %d, next attempt %s", p.Attempts, p.NextAttempt)
+	}
+	if p.Trzba.KontrolniKody.Pkp.Value != outcome.Pkp() || p.Trzba.KontrolniKody.Bkp.Value != outcome.Bkp() {
+		t.Error("stored Trzba differs in control codes")
+	}
+	if len(warned) != 1 || !items[0].DeadlineWarned {
+		t.Errorf("expected one deadline warning, got %d", len(warned))
+	}
+}
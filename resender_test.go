@@ -0,0 +1,102 @@
+package eet_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prochac/eet"
+	"github.com/prochac/eet/eettest"
+)
+
+func TestResender_Rejected(t *testing.T) {
+	srv, d := newTestDispatcher(t)
+	defer srv.Close()
+	srv.Enqueue(eettest.Reply{Status: 503})
+
+	store := eet.NewMemoryStore()
+	var rejected []error
+	var confirmed int
+	resender, err := eet.NewResender(d, store,
+		eet.WithBackoff(0, 0),
+		eet.OnRejected(func(p eet.PendingTrzba, err error) { rejected = append(rejected, err) }),
+		eet.OnConfirmed(func(eet.PendingTrzba, *eet.Response) { confirmed++ }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		outcome := d.Submit(context.Background(), newReceipt())
+		if i == 0 && !outcome.Offline() {
+			t.Fatalf("expected offline outcome, got %v", outcome.Err)
+		}
+		if i == 1 {
+			// the response of the second receipt was lost
+			outcome = eet.Outcome{Trzba: outcome.Trzba, Err: &eet.TimeoutError{Err: context.DeadlineExceeded}}
+		}
+		if err := resender.Enqueue(outcome); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(time.Millisecond)
+	srv.Enqueue(eettest.Reply{Code: eet.CodeSchemaViolation}, eettest.Reply{Status: 503})
+	if err := resender.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 1 || !errors.Is(rejected[0], eet.ErrSchemaViolation) {
+		t.Errorf("expected one rejection with schema violation, got %v", rejected)
+	}
+	items, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Attempts != 2 || confirmed != 0 {
+		t.Errorf("expected the retryable item kept with 2 attempts, got %+v", items)
+	}
+}
+
+func TestResender_SignatureError(t *testing.T) {
+	ca, err := eettest.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// responses are signed by a CA the dispatcher does not trust
+	srv, d := newTestDispatcher(t, eet.WithResponseRoots(ca.Pool()))
+	defer srv.Close()
+
+	store := eet.NewMemoryStore()
+	var rejected int
+	resender, err := eet.NewResender(d, store,
+		eet.WithBackoff(0, 0),
+		eet.OnRejected(func(eet.PendingTrzba, error) { rejected++ }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcome := d.Submit(context.Background(), newReceipt())
+	var sigErr *eet.SignatureError
+	if !errors.As(outcome.Err, &sigErr) || !outcome.Offline() {
+		t.Fatalf("expected offline outcome with SignatureError, got %v", outcome.Err)
+	}
+	if err := resender.Enqueue(outcome); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond)
+	err = resender.Flush(context.Background())
+	if !errors.As(err, &sigErr) {
+		t.Errorf("expected Flush to report the SignatureError, got %v", err)
+	}
+	items, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || rejected != 0 {
+		t.Fatalf("expected the item kept and not rejected, got %d items and %d rejections", len(items), rejected)
+	}
+	if items[0].Trzba.Hlavicka.UuidZpravy != outcome.Trzba.Hlavicka.UuidZpravy || items[0].LastError == "" {
+		t.Errorf("unexpected pending item %+v", items[0])
+	}
+}
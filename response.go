@@ -1,20 +1,73 @@
 package eet
 
 import (
+	"fmt"
 	"time"
 )
 
 type Response struct {
-	odpoved Odpoved
-	DatPrij time.Time
-	Fik     string
-	Bkp     string
+	odpoved    Odpoved
+	UuidZpravy string
+	DatPrij    time.Time
+	// DatOdmit is the time of processing of a message sent in verification mode.
+	DatOdmit time.Time
+	Fik      string
+	Bkp      string
+	// Test is set when the response comes from the playground, its Fik is not valid.
+	Test bool
+	// Overeni is set for a response to a message sent in verification mode.
+	// Such a response carries no Fik, it only confirms the message would be accepted.
+	Overeni bool
 }
 
-func (r Response) Warnings() []string {
-	warnings := make([]string, len(r.odpoved.Varovani))
-	for i, v := range r.odpoved.Varovani {
-		warnings[i] = v.Varovani
+// WarningCode is the code of a warning (kod_varov in Varovani).
+type WarningCode int
+
+// Warning codes documented by the EET interface specification.
+const (
+	WarningDicMismatch            WarningCode = 1 // DIC of the taxpayer differs from DIC in the certificate
+	WarningInvalidDicPoverujiciho WarningCode = 2 // invalid format of DicPoverujiciho
+	WarningInvalidPkp             WarningCode = 3 // invalid PKP value
+	WarningDatTrzbyAfterDatPrij   WarningCode = 4 // dat_trzby is later than the time the message was received
+	WarningDatTrzbyFarInPast      WarningCode = 5 // dat_trzby is significantly in the past
+)
+
+var warningMessages = map[WarningCode]string{
+	WarningDicMismatch:            "DIC of the taxpayer does not match DIC in the certificate",
+	WarningInvalidDicPoverujiciho: "invalid format of DIC of the delegating taxpayer",
+	WarningInvalidPkp:             "invalid PKP value",
+	WarningDatTrzbyAfterDatPrij:   "date and time of the sale is later than the time the message was received",
+	WarningDatTrzbyFarInPast:      "date and time of the sale is significantly in the past",
+}
+
+// String returns the English description of the code.
+func (c WarningCode) String() string {
+	if m, ok := warningMessages[c]; ok {
+		return m
+	}
+	return "unknown warning"
+}
+
+// Warning is a warning returned by the server with an accepted receipt.
+type Warning struct {
+	Code WarningCode
+	// Message is the text sent by the server, in Czech.
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%d %s", w.Code, w.Message)
+}
+
+// Warnings returns warnings of the response, the receipt was accepted regardless.
+func (r Response) Warnings() []Warning {
+	return warnings(r.odpoved)
+}
+
+func warnings(odpoved Odpoved) []Warning {
+	warnings := make([]Warning, len(odpoved.Varovani))
+	for i, v := range odpoved.Varovani {
+		warnings[i] = Warning{Code: v.KodVarov, Message: v.Varovani}
 	}
 	return warnings
 }
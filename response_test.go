@@ -0,0 +1,55 @@
+package eet
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestResponse_Warnings(t *testing.T) {
+	data := `<Odpoved xmlns="http://fs.mfcr.cz/eet/schema/v3">
+	<Hlavicka uuid_zpravy="b3a09b52-7c87-4014-a496-4c7a53cf9120" bkp="01234567-89abcdef-01234567-89abcdef-01234567" dat_prij="2019-01-01T10:00:01+01:00"/>
+	<Potvrzeni fik="b3309b52-7c87-4014-a496-4c7a53cf9125-03" test="true"/>
+	<Varovani kod_varov="1">DIC poplatnika v datove zprave se neshoduje s DIC v certifikatu</Varovani>
+	<Varovani kod_varov="5">Datum a cas prijeti trzby je vyrazne v minulosti</Varovani>
+</Odpoved>`
+	var odpoved Odpoved
+	if err := xml.Unmarshal([]byte(data), &odpoved); err != nil {
+		t.Fatal(err)
+	}
+	warnings := Response{odpoved: odpoved}.Warnings()
+	want := []Warning{
+		{Code: WarningDicMismatch, Message: "DIC poplatnika v datove zprave se neshoduje s DIC v certifikatu"},
+		{Code: WarningDatTrzbyFarInPast, Message: "Datum a cas prijeti trzby je vyrazne v minulosti"},
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("expected %d warnings, got %v", len(want), warnings)
+	}
+	for i := range want {
+		if warnings[i] != want[i] {
+			t.Errorf("warning %d = %v, want %v", i, warnings[i], want[i])
+		}
+	}
+	if got := warnings[1].String(); got != "5 Datum a cas prijeti trzby je vyrazne v minulosti" {
+		t.Errorf("unexpected String() %q", got)
+	}
+	if len((Response{}).Warnings()) != 0 {
+		t.Error("expected no warnings of an empty response")
+	}
+}
+
+func TestWarningCode_String(t *testing.T) {
+	tests := []struct {
+		code WarningCode
+		want string
+	}{
+		{WarningDicMismatch, "DIC of the taxpayer does not match DIC in the certificate"},
+		{WarningInvalidPkp, "invalid PKP value"},
+		{WarningDatTrzbyFarInPast, "date and time of the sale is significantly in the past"},
+		{WarningCode(42), "unknown warning"},
+	}
+	for _, tt := range tests {
+		if got := tt.code.String(); got != tt.want {
+			t.Errorf("WarningCode(%d).String() = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package eet
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures how a Dispatcher resends a message that failed with
+// a retryable error, see IsRetryable. Retries are sent with PrvniZaslani false
+// and a fresh dat_odesl, keeping UuidZpravy, PKP and BKP, so the server can
+// recognise the same receipt.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one,
+	// values below 2 disable retries.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, doubled on every
+	// further retry up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Jitter randomizes every delay by up to the given fraction,
+	// e.g. 0.2 for ±20 %, so retries of many clients do not synchronize.
+	Jitter float64
+	// Budget bounds all attempts including the delays, e.g. the 2 seconds
+	// required by law. Only the context bounds them when zero.
+	Budget time.Duration
+}
+
+// DefaultRetryPolicy tries a message up to three times within the 2 seconds
+// the law allows for the response.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  500 * time.Millisecond,
+	Jitter:      0.2,
+	Budget:      2 * time.Second,
+}
+
+// backoff returns the delay before the given retry, 1 for the first one.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.MinBackoff
+	for i := 1; i < retry && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// sendRetrying sends trzba and resends it according to the retry policy of
+// the Dispatcher. It returns the Trzba of the last attempt.
+func (d *Dispatcher) sendRetrying(ctx context.Context, trzba Trzba, signer *Signer) (Trzba, *Response, error) {
+	policy := d.retryPolicy
+	if policy.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Budget)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		response, err := d.send(ctx, trzba, signer, attempt)
+		if err == nil || attempt >= policy.MaxAttempts || !IsRetryable(err) || ctx.Err() != nil {
+			return trzba, response, err
+		}
+
+		delay := policy.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return trzba, response, err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return trzba, response, err
+		}
+
+		trzba.Hlavicka.PrvniZaslani = false
+		trzba.Hlavicka.DatOdesl = NewDateTimeType(time.Now())
+	}
+}
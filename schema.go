@@ -0,0 +1,197 @@
+package eet
+
+//go:generate go run gen_schema.go
+
+// EETSchema is the XML schema of the Trzba and Odpoved messages used by
+// ValidateXML. It is written after the types of EETXMLSchema v3 as described
+// by the EET interface specification, it is not the official
+// EETXMLSchema.xsd. Place the official file to testdata/EETXMLSchema.xsd and
+// run go generate to replace it by the official schema verbatim.
+const EETSchema = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:eet="http://fs.mfcr.cz/eet/schema/v3" targetNamespace="http://fs.mfcr.cz/eet/schema/v3" elementFormDefault="qualified" attributeFormDefault="unqualified" version="3">
+	<xs:simpleType name="CastkaType">
+		<xs:restriction base="xs:decimal">
+			<xs:totalDigits value="12"/>
+			<xs:fractionDigits value="2"/>
+			<xs:minInclusive value="-99999999.99"/>
+			<xs:maxInclusive value="99999999.99"/>
+			<xs:pattern value="((0|-?[1-9]\d{0,7})\.\d\d|-0\.(0[1-9]|[1-9]\d))"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="CZDICType">
+		<xs:restriction base="xs:string">
+			<xs:pattern value="CZ[0-9]{8,10}"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="dateTime">
+		<xs:restriction base="xs:dateTime">
+			<xs:pattern value="\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d[+\-]\d\d:\d\d"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="IdProvozType">
+		<xs:restriction base="xs:int">
+			<xs:minInclusive value="1"/>
+			<xs:maxInclusive value="999999"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="string20">
+		<xs:restriction base="xs:string">
+			<xs:pattern value="[0-9a-zA-Z\.,:;/#\-_ ]{1,20}"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="string25">
+		<xs:restriction base="xs:string">
+			<xs:pattern value="[0-9a-zA-Z\.,:;/#\-_ ]{1,25}"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="RezimType">
+		<xs:restriction base="xs:int">
+			<xs:enumeration value="0"/>
+			<xs:enumeration value="1"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="UUIDType">
+		<xs:restriction base="xs:string">
+			<xs:pattern value="[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[1-5][0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="PkpType">
+		<xs:restriction base="xs:base64Binary">
+			<xs:length value="256"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="BkpType">
+		<xs:restriction base="xs:string">
+			<xs:pattern value="[0-9a-fA-F]{8}-[0-9a-fA-F]{8}-[0-9a-fA-F]{8}-[0-9a-fA-F]{8}-[0-9a-fA-F]{8}"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="FikType">
+		<xs:restriction base="xs:string">
+			<xs:pattern value="[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}-[0-9a-fA-F]{2}"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="PkpDigestType">
+		<xs:restriction base="xs:string">
+			<xs:enumeration value="SHA256"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="PkpCipherType">
+		<xs:restriction base="xs:string">
+			<xs:enumeration value="RSA2048"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="PkpEncodingType">
+		<xs:restriction base="xs:string">
+			<xs:enumeration value="base64"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="BkpDigestType">
+		<xs:restriction base="xs:string">
+			<xs:enumeration value="SHA1"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="BkpEncodingType">
+		<xs:restriction base="xs:string">
+			<xs:enumeration value="base16"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:complexType name="TrzbaHlavickaType">
+		<xs:attribute name="uuid_zpravy" type="eet:UUIDType" use="required"/>
+		<xs:attribute name="dat_odesl" type="eet:dateTime" use="required"/>
+		<xs:attribute name="prvni_zaslani" type="xs:boolean" use="required"/>
+		<xs:attribute name="overeni" type="xs:boolean" use="optional"/>
+	</xs:complexType>
+	<xs:complexType name="TrzbaDataType">
+		<xs:attribute name="dic_popl" type="eet:CZDICType" use="required"/>
+		<xs:attribute name="dic_poverujiciho" type="eet:CZDICType" use="optional"/>
+		<xs:attribute name="id_provoz" type="eet:IdProvozType" use="required"/>
+		<xs:attribute name="id_pokl" type="eet:string20" use="required"/>
+		<xs:attribute name="porad_cis" type="eet:string25" use="required"/>
+		<xs:attribute name="dat_trzby" type="eet:dateTime" use="required"/>
+		<xs:attribute name="celk_trzba" type="eet:CastkaType" use="required"/>
+		<xs:attribute name="zakl_nepodl_dph" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="zakl_dan1" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="dan1" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="zakl_dan2" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="dan2" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="zakl_dan3" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="dan3" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="cest_sluz" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="pouzit_zboz1" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="pouzit_zboz2" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="pouzit_zboz3" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="urceno_cerp_zuct" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="cerp_zuct" type="eet:CastkaType" use="optional"/>
+		<xs:attribute name="rezim" type="eet:RezimType" use="required"/>
+	</xs:complexType>
+	<xs:complexType name="PkpElementType">
+		<xs:simpleContent>
+			<xs:extension base="eet:PkpType">
+				<xs:attribute name="digest" type="eet:PkpDigestType" use="required"/>
+				<xs:attribute name="cipher" type="eet:PkpCipherType" use="required"/>
+				<xs:attribute name="encoding" type="eet:PkpEncodingType" use="required"/>
+			</xs:extension>
+		</xs:simpleContent>
+	</xs:complexType>
+	<xs:complexType name="BkpElementType">
+		<xs:simpleContent>
+			<xs:extension base="eet:BkpType">
+				<xs:attribute name="digest" type="eet:BkpDigestType" use="required"/>
+				<xs:attribute name="encoding" type="eet:BkpEncodingType" use="required"/>
+			</xs:extension>
+		</xs:simpleContent>
+	</xs:complexType>
+	<xs:complexType name="TrzbaKontrolniKodyType">
+		<xs:sequence>
+			<xs:element name="pkp" type="eet:PkpElementType"/>
+			<xs:element name="bkp" type="eet:BkpElementType"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:element name="Trzba">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="Hlavicka" type="eet:TrzbaHlavickaType"/>
+				<xs:element name="Data" type="eet:TrzbaDataType"/>
+				<xs:element name="KontrolniKody" type="eet:TrzbaKontrolniKodyType"/>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+	<xs:complexType name="OdpovedHlavickaType">
+		<xs:attribute name="uuid_zpravy" type="eet:UUIDType" use="optional"/>
+		<xs:attribute name="bkp" type="eet:BkpType" use="optional"/>
+		<xs:attribute name="dat_prij" type="eet:dateTime" use="optional"/>
+		<xs:attribute name="dat_odmit" type="eet:dateTime" use="optional"/>
+	</xs:complexType>
+	<xs:complexType name="OdpovedPotvrzeniType">
+		<xs:attribute name="fik" type="eet:FikType" use="required"/>
+		<xs:attribute name="test" type="xs:boolean" use="optional"/>
+	</xs:complexType>
+	<xs:complexType name="OdpovedChybaType">
+		<xs:simpleContent>
+			<xs:extension base="xs:string">
+				<xs:attribute name="kod" type="xs:int" use="required"/>
+				<xs:attribute name="test" type="xs:boolean" use="optional"/>
+			</xs:extension>
+		</xs:simpleContent>
+	</xs:complexType>
+	<xs:complexType name="OdpovedVarovaniType">
+		<xs:simpleContent>
+			<xs:extension base="xs:string">
+				<xs:attribute name="kod_varov" type="xs:int" use="required"/>
+			</xs:extension>
+		</xs:simpleContent>
+	</xs:complexType>
+	<xs:element name="Odpoved">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="Hlavicka" type="eet:OdpovedHlavickaType"/>
+				<xs:choice>
+					<xs:element name="Potvrzeni" type="eet:OdpovedPotvrzeniType"/>
+					<xs:element name="Chyba" type="eet:OdpovedChybaType"/>
+				</xs:choice>
+				<xs:element name="Varovani" type="eet:OdpovedVarovaniType" minOccurs="0" maxOccurs="unbounded"/>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>
+`
@@ -0,0 +1,171 @@
+package eet
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sequencer allocates porad_cis, the serial numbers of receipts.
+type Sequencer interface {
+	// Next allocates the next number of the cash register idPokl in the
+	// establishment idProvoz of the taxpayer dic. A number is never
+	// returned twice.
+	Next(dic CZDICType, idProvoz IdProvozType, idPokl string) (string, error)
+}
+
+// SequenceFormat formats the numbers allocated by FileSequencer.
+// Parts are joined by "/", e.g. "A/2024/000042".
+type SequenceFormat struct {
+	// Prefix precedes the number, e.g. a code of the branch.
+	Prefix string
+	// Yearly restarts the counter every year and puts the year into the number.
+	Yearly bool
+	// Width pads the counter with zeros to the given number of digits.
+	Width int
+}
+
+// Format returns the n-th number allocated in year.
+func (f SequenceFormat) Format(year int, n uint64) string {
+	var parts []string
+	if f.Prefix != "" {
+		parts = append(parts, f.Prefix)
+	}
+	if f.Yearly {
+		parts = append(parts, strconv.Itoa(year))
+	}
+	parts = append(parts, fmt.Sprintf("%0*d", f.Width, n))
+	return strings.Join(parts, "/")
+}
+
+// FileSequencer is a Sequencer keeping a counter per taxpayer, establishment
+// and cash register in a directory. Every number is written and synced to
+// disk before it is returned, so numbers are not repeated after a crash or
+// restart, and none is skipped unless an allocated number is left unused.
+// The directory must not be shared by more processes.
+type FileSequencer struct {
+	dir    string
+	format SequenceFormat
+	now    func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewFileSequencer creates a FileSequencer allocating numbers in format,
+// counters are kept in dir.
+func NewFileSequencer(dir string, format SequenceFormat) (*FileSequencer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating directory %s: %w", dir, err)
+	}
+	if _, err := NewString25(format.Format(9999, 1)); err != nil {
+		return nil, fmt.Errorf("invalid sequence prefix %q: %w", format.Prefix, err)
+	}
+	return &FileSequencer{dir: dir, format: format, now: time.Now}, nil
+}
+
+// sequenceKey identifies a counter of FileSequencer.
+type sequenceKey struct {
+	dic      CZDICType
+	idProvoz IdProvozType
+	idPokl   string
+}
+
+func (k sequenceKey) String() string {
+	return fmt.Sprintf("%s/%d/%s", k.dic, k.idProvoz, k.idPokl)
+}
+
+func (s *FileSequencer) path(k sequenceKey) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s-%d-%s.seq", url.PathEscape(string(k.dic)), k.idProvoz, url.PathEscape(k.idPokl)))
+}
+
+// Next implements Sequencer. With SequenceFormat.Yearly, it fails when the
+// clock is set before the year of the last allocated number, so no number is
+// allocated twice.
+func (s *FileSequencer) Next(dic CZDICType, idProvoz IdProvozType, idPokl string) (string, error) {
+	if dic == "" || idProvoz == 0 || idPokl == "" {
+		return "", errors.New("dic_popl, id_provoz and id_pokl are required")
+	}
+	k := sequenceKey{dic: dic, idProvoz: idProvoz, idPokl: idPokl}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	year, n, err := s.load(k)
+	if err != nil {
+		return "", err
+	}
+	now := s.now().Year()
+	switch {
+	case year == 0:
+		year, n = now, 0
+	case s.format.Yearly && now < year:
+		return "", fmt.Errorf("sequence of %s: clock in %d is before the last number allocated in %d", k, now, year)
+	case s.format.Yearly && now > year:
+		year, n = now, 0
+	}
+	n++
+	number, err := NewString25(s.format.Format(year, n))
+	if err != nil {
+		return "", fieldError("porad_cis", err)
+	}
+	if err := s.store(k, year, n); err != nil {
+		return "", err
+	}
+	return string(number), nil
+}
+
+// load returns the year and the last number allocated for k,
+// zeros when none was allocated yet.
+func (s *FileSequencer) load(k sequenceKey) (int, uint64, error) {
+	data, err := ioutil.ReadFile(s.path(k))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading sequence of %s: %w", k, err)
+	}
+	var year int
+	var n uint64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &year, &n); err != nil {
+		return 0, 0, fmt.Errorf("decoding sequence of %s: %w", k, err)
+	}
+	return year, n, nil
+}
+
+// store replaces the counter file of k atomically.
+func (s *FileSequencer) store(k sequenceKey, year int, n uint64) error {
+	tmp, err := ioutil.TempFile(s.dir, ".seq-")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := fmt.Fprintf(tmp, "%d %d\n", year, n); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("syncing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(k)); err != nil {
+		return fmt.Errorf("renaming %s: %w", tmp.Name(), err)
+	}
+	// the rename is durable only when the directory is synced,
+	// which is not supported on every platform
+	if dir, err := os.Open(s.dir); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package eet
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileSequencer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eet-sequence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	format := SequenceFormat{Prefix: "A", Yearly: true, Width: 4}
+	s, err := NewFileSequencer(dir, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2019, 12, 31, 23, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	numbers := make(chan string, 10)
+	for i := 0; i < cap(numbers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := s.Next("CZ00000019", 273, "/5546/RO24")
+			if err != nil {
+				t.Error(err)
+			}
+			numbers <- n
+		}()
+	}
+	wg.Wait()
+	close(numbers)
+	seen := make(map[string]bool)
+	for n := range numbers {
+		if seen[n] {
+			t.Errorf("number %s allocated twice", n)
+		}
+		seen[n] = true
+	}
+	if !seen["A/2019/0001"] || !seen["A/2019/0010"] {
+		t.Errorf("unexpected numbers %v", seen)
+	}
+
+	// a restarted sequencer continues, other cash registers have own counters
+	s, err = NewFileSequencer(dir, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now }
+	for _, tt := range []struct {
+		dic      CZDICType
+		idProvoz IdProvozType
+		idPokl   string
+		want     string
+	}{
+		{"CZ00000019", 273, "/5546/RO24", "A/2019/0011"},
+		{"CZ00000019", 273, "2", "A/2019/0001"},
+		{"CZ00000019", 11, "/5546/RO24", "A/2019/0001"},
+		{"CZ683555118", 273, "/5546/RO24", "A/2019/0001"},
+	} {
+		if n, err := s.Next(tt.dic, tt.idProvoz, tt.idPokl); err != nil || n != tt.want {
+			t.Errorf("Next(%s, %d, %q) = %q, %v, want %q", tt.dic, tt.idProvoz, tt.idPokl, n, err, tt.want)
+		}
+	}
+	if _, err := s.Next("CZ00000019", 0, "2"); err == nil {
+		t.Error("expected error for missing id_provoz")
+	}
+
+	now = now.Add(time.Hour)
+	if n, err := s.Next("CZ00000019", 273, "/5546/RO24"); err != nil || n != "A/2020/0001" {
+		t.Errorf("expected counter restarted in 2020, got %q, %v", n, err)
+	}
+
+	// a clock set back must not restart the counter
+	now = now.AddDate(-1, 0, 0)
+	if n, err := s.Next("CZ00000019", 273, "/5546/RO24"); err == nil {
+		t.Errorf("expected error for clock set back, got %q", n)
+	}
+	now = now.AddDate(1, 0, 0)
+	if n, err := s.Next("CZ00000019", 273, "/5546/RO24"); err != nil || n != "A/2020/0002" {
+		t.Errorf("expected counter continued, got %q, %v", n, err)
+	}
+
+	if _, err := NewFileSequencer(dir, SequenceFormat{Prefix: "not valid!"}); err == nil {
+		t.Error("expected error for invalid prefix")
+	}
+}
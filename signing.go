@@ -6,33 +6,126 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"regexp"
+	"time"
 
 	"golang.org/x/crypto/pkcs12"
 )
 
+// Signer signs EET messages with the taxpayer's private key. The key can be
+// any crypto.Signer, e.g. one backed by an HSM, a TPM or a smart card,
+// so it never has to be present in memory.
 type Signer struct {
-	cert *x509.Certificate
-	key  *rsa.PrivateKey
+	cert  *x509.Certificate
+	chain []*x509.Certificate
+	key   crypto.Signer
 }
 
+// NewSigner reads the key and certificate from a PKCS#12 (.p12) file.
 func NewSigner(certPath string, password string) (*Signer, error) {
 	pfxData, err := ioutil.ReadFile(certPath)
 	if err != nil {
 		return nil, fmt.Errorf("reading file %s: %w", certPath, err)
 	}
 
-	privateKey, certificate, _, err := decodeAll(pfxData, password)
+	return NewSignerFromPKCS12(pfxData, password)
+}
+
+// NewSignerFromReader reads the key and certificate in PKCS#12 format from r.
+func NewSignerFromReader(r io.Reader, password string) (*Signer, error) {
+	pfxData, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading PKCS#12 data: %w", err)
+	}
+
+	return NewSignerFromPKCS12(pfxData, password)
+}
+
+// NewSignerFromPKCS12 decodes the key and certificate from PKCS#12 data,
+// including files using AES (PBES2) and SHA-2 MACs exported by OpenSSL 3 or
+// Windows. A wrong password results in an error matching ErrIncorrectPassword,
+// an unsupported file in one matching ErrUnsupportedPKCS12. Other certificates
+// in the data are kept as the chain.
+func NewSignerFromPKCS12(pfxData []byte, password string) (*Signer, error) {
+	privateKey, certificate, chain, err := decodeAll(pfxData, password)
 	if err != nil {
 		return nil, fmt.Errorf("decoding private key and certificates: %w", err)
 	}
 
+	return NewCryptoSigner(privateKey, certificate, chain...)
+}
+
+// NewSignerFromPEM decodes an unencrypted PKCS#1 or PKCS#8 private key and the
+// certificate followed by its chain from PEM data.
+func NewSignerFromPEM(keyPEM, certPEM []byte) (*Signer, error) {
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, errors.New("no PEM block with private key found")
+	}
+	var key crypto.Signer
+	switch keyBlock.Type {
+	case "RSA PRIVATE KEY":
+		rsaKey, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		key = rsaKey
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		var ok bool
+		if key, ok = parsed.(crypto.Signer); !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", parsed)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", keyBlock.Type)
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM block with certificate found")
+	}
+
+	return NewCryptoSigner(key, certs[0], certs[1:]...)
+}
+
+// NewCryptoSigner creates a Signer using key, whose public part has to match
+// cert. EET requires an RSA key. chain holds the intermediate and CA
+// certificates of cert, if known.
+func NewCryptoSigner(key crypto.Signer, cert *x509.Certificate, chain ...*x509.Certificate) (*Signer, error) {
+	if key == nil || cert == nil {
+		return nil, errors.New("key and certificate are required")
+	}
+	if _, ok := key.Public().(*rsa.PublicKey); !ok {
+		return nil, fmt.Errorf("unsupported public key type %T, RSA expected", key.Public())
+	}
+	if !samePublicKey(cert.PublicKey, key.Public()) {
+		return nil, errors.New("private key does not match the certificate")
+	}
+
 	s := Signer{
-		key:  privateKey,
-		cert: certificate,
+		key:   key,
+		cert:  cert,
+		chain: chain,
 	}
 
 	return &s, nil
@@ -43,54 +136,150 @@ func (s *Signer) Base64Cert() string {
 	return base64.StdEncoding.EncodeToString(rawCert)
 }
 
+// Certificate returns the taxpayer's certificate.
+func (s *Signer) Certificate() *x509.Certificate {
+	return s.cert
+}
+
+// Chain returns the intermediate and CA certificates of the certificate,
+// e.g. the CA certificate included in the .p12 file.
+func (s *Signer) Chain() []*x509.Certificate {
+	return s.chain
+}
+
+// Subject returns the subject of the certificate.
+func (s *Signer) Subject() pkix.Name {
+	return s.cert.Subject
+}
+
+// Issuer returns the issuer of the certificate, e.g. EET CA 1.
+func (s *Signer) Issuer() pkix.Name {
+	return s.cert.Issuer
+}
+
+// NotBefore returns the start of the validity of the certificate.
+func (s *Signer) NotBefore() time.Time {
+	return s.cert.NotBefore
+}
+
+// NotAfter returns the end of the validity of the certificate.
+func (s *Signer) NotAfter() time.Time {
+	return s.cert.NotAfter
+}
+
+var certDicPattern = regexp.MustCompile(`^CZ[0-9]{8,10}$`)
+
+// DIC returns the DIC of the taxpayer the certificate was issued to,
+// which EET certificates carry as the whole subject common name.
+func (s *Signer) DIC() (CZDICType, error) {
+	cn := s.cert.Subject.CommonName
+	if !certDicPattern.MatchString(cn) {
+		return "", fmt.Errorf("no DIC in certificate common name %q", cn)
+	}
+	return CZDICType(cn), nil
+}
+
+var (
+	// ErrCertificateExpired is returned by CheckValidity after the certificate expired.
+	ErrCertificateExpired = errors.New("eet: certificate expired")
+	// ErrCertificateNotYetValid is returned by CheckValidity before the certificate is valid.
+	ErrCertificateNotYetValid = errors.New("eet: certificate not yet valid")
+)
+
+// CheckValidity checks that the certificate is valid at now. The returned
+// error matches ErrCertificateExpired or ErrCertificateNotYetValid.
+func (s *Signer) CheckValidity(now time.Time) error {
+	if now.Before(s.cert.NotBefore) {
+		return fmt.Errorf("%w: valid from %s", ErrCertificateNotYetValid, s.cert.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(s.cert.NotAfter) {
+		return fmt.Errorf("%w: valid until %s", ErrCertificateExpired, s.cert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
 // Sign signs data with rsa-sha256
 func (s *Signer) Sign(data []byte) ([]byte, error) {
 	hashed := sha256.Sum256(data)
-	return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hashed[:])
+	return s.key.Sign(rand.Reader, hashed[:], crypto.SHA256)
 }
 
-// decodeAll extracts all certificate and private keys from pfxData.
-func decodeAll(pfxData []byte, password string) (*rsa.PrivateKey, *x509.Certificate, *x509.Certificate, error) {
+// decodeAll extracts the private key, its certificate and the other
+// certificates of the chain from pfxData.
+func decodeAll(pfxData []byte, password string) (crypto.Signer, *x509.Certificate, []*x509.Certificate, error) {
+	keys, certs, err := decodePKCS12(pfxData, password)
+	if err == errLegacyPKCS12 {
+		keys, certs, err = decodeLegacyPKCS12(pfxData, password)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	switch len(keys) {
+	case 0:
+		return nil, nil, nil, errors.New("no private key found")
+	case 1:
+	default:
+		return nil, nil, nil, errors.New("only one private key expected")
+	}
+
 	var (
-		privateKey  *rsa.PrivateKey
 		certificate *x509.Certificate
-		caCert      *x509.Certificate
+		chain       []*x509.Certificate
 	)
+	for _, cert := range certs {
+		if certificate == nil && samePublicKey(cert.PublicKey, keys[0].Public()) {
+			certificate = cert
+			continue
+		}
+		chain = append(chain, cert)
+	}
+	if certificate == nil {
+		return nil, nil, nil, errors.New("no certificate matching the private key found")
+	}
+
+	return keys[0], certificate, chain, nil
+}
+
+// decodeLegacyPKCS12 decodes pfxData by golang.org/x/crypto/pkcs12,
+// which supports RC2 encryption.
+func decodeLegacyPKCS12(pfxData []byte, password string) ([]crypto.Signer, []*x509.Certificate, error) {
 	blocks, err := pkcs12.ToPEM(pfxData, password)
+	if err == pkcs12.ErrIncorrectPassword {
+		return nil, nil, ErrIncorrectPassword
+	}
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("converting binary pfx data to PEM: %w", err)
+		return nil, nil, fmt.Errorf("converting binary pfx data to PEM: %w", err)
 	}
+
+	var (
+		keys  []crypto.Signer
+		certs []*x509.Certificate
+	)
 	for _, block := range blocks {
 		switch block.Type {
 		case "PRIVATE KEY":
-			if privateKey != nil {
-				return nil, nil, nil, errors.New("only one private key expected")
-			}
-
-			privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+			privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 			if err != nil {
-				return nil, nil, nil, fmt.Errorf("parsing private key: %w", err)
+				return nil, nil, fmt.Errorf("parsing private key: %w", err)
 			}
+			keys = append(keys, privateKey)
 		case "CERTIFICATE":
-			certs, err := x509.ParseCertificates(block.Bytes)
+			cert, err := x509.ParseCertificate(block.Bytes)
 			if err != nil {
-				return nil, nil, nil, fmt.Errorf("parsing cetrtificates: %w", err)
-			}
-			for _, cert := range certs {
-				if cert.IsCA {
-					if caCert != nil {
-						return nil, nil, nil, errors.New("only one CA certificate expected")
-					}
-					caCert = cert
-					continue
-				}
-				if certificate != nil {
-					return nil, nil, nil, errors.New("only one certificate expected")
-				}
-				certificate = cert
+				return nil, nil, fmt.Errorf("parsing certificate: %w", err)
 			}
+			certs = append(certs, cert)
 		}
 	}
+	return keys, certs, nil
+}
 
-	return privateKey, certificate, caCert, nil
+// samePublicKey reports whether a and b are the same RSA public key.
+func samePublicKey(a, b crypto.PublicKey) bool {
+	aKey, ok := a.(*rsa.PublicKey)
+	if !ok {
+		return false
+	}
+	bKey, ok := b.(*rsa.PublicKey)
+	return ok && aKey.N.Cmp(bKey.N) == 0 && aKey.E == bKey.E
 }
@@ -0,0 +1,197 @@
+package eet
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// hsmSigner mimics a hardware-backed key: it signs digests without exposing
+// the private key.
+type hsmSigner struct {
+	key   *rsa.PrivateKey
+	calls int
+}
+
+func (h *hsmSigner) Public() crypto.PublicKey {
+	return &h.key.PublicKey
+}
+
+func (h *hsmSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	h.calls++
+	return rsa.SignPKCS1v15(rand, h.key, opts.HashFunc(), digest)
+}
+
+func TestNewCryptoSigner(t *testing.T) {
+	ca := newTestCA(t)
+	signer := ca.newSigner(t, "CZ00000019")
+	key := signer.key.(*rsa.PrivateKey)
+
+	hsm := &hsmSigner{key: key}
+	wrapped, err := NewCryptoSigner(hsm, signer.cert, ca.cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("CZ00000019|273|/5546/RO24|0/6460/ZQ42|2016-08-05T00:30:12+02:00|34113.00")
+	want, err := signer.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := wrapped.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hsm.calls != 1 {
+		t.Errorf("expected one call of the crypto.Signer, got %d", hsm.calls)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("signature differs from the one made with the private key")
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCryptoSigner(otherKey, signer.cert); err == nil {
+		t.Error("expected error for a key not matching the certificate")
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCryptoSigner(ecKey, signer.cert); err == nil {
+		t.Error("expected error for a non-RSA key")
+	}
+}
+
+func TestNewSignerFromPEM(t *testing.T) {
+	ca := newTestCA(t)
+	signer := ca.newSigner(t, "CZ00000019")
+	key := signer.key.(*rsa.PrivateKey)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEMs := map[string][]byte{
+		"PKCS#1": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		"PKCS#8": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+	}
+	certPEM := append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signer.cert.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...,
+	)
+
+	for name, keyPEM := range keyPEMs {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewSignerFromPEM(keyPEM, certPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.cert.Equal(signer.cert) {
+				t.Error("unexpected certificate")
+			}
+			if len(got.chain) != 1 || !got.chain[0].Equal(ca.cert) {
+				t.Errorf("unexpected chain of %d certificates", len(got.chain))
+			}
+		})
+	}
+}
+
+func TestSigner_Certificate(t *testing.T) {
+	ca := newTestCA(t)
+	signer := ca.newSigner(t, "CZ00000019")
+
+	dic, err := signer.DIC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dic != "CZ00000019" {
+		t.Errorf("unexpected DIC %s", dic)
+	}
+	if signer.Issuer().CommonName != "EET CA Test" {
+		t.Errorf("unexpected issuer %s", signer.Issuer())
+	}
+	if len(signer.Chain()) != 1 {
+		t.Errorf("unexpected chain of %d certificates", len(signer.Chain()))
+	}
+	for _, cn := range []string{"EET server", "CZ12345678901", "XCZ00000019", "CZ00000019 test", "ACZ1234567890"} {
+		if dic, err := ca.newSigner(t, cn).DIC(); err == nil {
+			t.Errorf("expected error for common name %q, got DIC %s", cn, dic)
+		}
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want error
+	}{
+		{name: "valid", now: time.Now()},
+		{name: "expired", now: signer.NotAfter().Add(time.Second), want: ErrCertificateExpired},
+		{name: "not yet valid", now: signer.NotBefore().Add(-time.Second), want: ErrCertificateNotYetValid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := signer.CheckValidity(tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("CheckValidity() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSignerFromPKCS12(t *testing.T) {
+	tests := []struct {
+		file     string
+		password string
+		err      error
+	}{
+		{file: "aes256-sha256.p12", password: "eet"},
+		{file: "aes128-sha512.p12", password: "eet"},
+		{file: "3des-sha1.p12", password: "eet"},
+		{file: "rc2-sha1.p12", password: "eet"},
+		{file: "empty-password.p12", password: ""},
+		{file: "aes256-sha256.p12", password: "wrong", err: ErrIncorrectPassword},
+		{file: "rc2-sha1.p12", password: "wrong", err: ErrIncorrectPassword},
+		{file: "../EET_CA1_Playground-ca.crt", password: "eet", err: ErrUnsupportedPKCS12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join("testdata", "pkcs12", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer, err := NewSignerFromPKCS12(data, tt.password)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("NewSignerFromPKCS12() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signer.Subject().CommonName != "CZ00000019" {
+				t.Errorf("unexpected certificate %s", signer.Subject())
+			}
+			if len(signer.Chain()) != 2 {
+				t.Errorf("expected chain of 2 certificates, got %d", len(signer.Chain()))
+			}
+			if _, err := signer.Sign([]byte("data")); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
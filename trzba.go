@@ -7,56 +7,108 @@ import (
 	"encoding/xml"
 	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+// Patterns of the simple types of EETXMLSchema v3. XSD patterns always match
+// the whole value, hence the anchors.
+var (
+	string20Pattern = regexp.MustCompile(`^[0-9a-zA-Z\.,:;/#\-_ ]{1,20}$`)
+	string25Pattern = regexp.MustCompile(`^[0-9a-zA-Z\.,:;/#\-_ ]{1,25}$`)
+	castkaPattern   = regexp.MustCompile(`^((0|-?[1-9]\d{0,7})\.\d\d|-0\.(0[1-9]|[1-9]\d))$`)
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[1-5][0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}$`)
+	czDicPattern    = regexp.MustCompile(`^CZ[0-9]{8,10}$`)
+	fikPattern      = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}-[0-9a-fA-F]{2}$`)
+)
+
+// ValidationError reports a value violating a constraint of the EET XML schema.
+type ValidationError struct {
+	// Field is the XML name of the attribute, e.g. "id_pokl", empty when not known.
+	Field string
+	// Type is the schema type, e.g. "string20".
+	Type       string
+	Value      string
+	Constraint string
+}
+
+func (e *ValidationError) Error() string {
+	name := e.Type
+	if e.Field != "" {
+		name = e.Field + " (" + e.Type + ")"
+	}
+	return fmt.Sprintf("eet: invalid %s %q: %s", name, e.Value, e.Constraint)
+}
+
+func patternError(typ, value string, pattern *regexp.Regexp) *ValidationError {
+	constraint := strings.TrimSuffix(strings.TrimPrefix(pattern.String(), "^"), "$")
+	return &ValidationError{Type: typ, Value: value, Constraint: "does not match pattern " + constraint}
+}
+
+// fieldError sets the field name of a *ValidationError returned by a constructor.
+func fieldError(field string, err error) error {
+	if vErr, ok := err.(*ValidationError); ok {
+		e := *vErr
+		e.Field = field
+		return &e
+	}
+	return errors.Wrapf(err, "Failed to create %s", field)
+}
+
 type String20 string
 
 func NewString20(string20 string) (String20, error) {
-	regex := `[0-9a-zA-Z\.,:;/#\-_ ]{1,20}`
-	if ok, _ := regexp.MatchString(regex, string20); ok {
-		return String20(string20), nil
+	if !string20Pattern.MatchString(string20) {
+		return "", patternError("string20", string20, string20Pattern)
 	}
-	return "", errors.New("invalid String20")
+	return String20(string20), nil
 }
 
 type String25 string
 
 func NewString25(string25 string) (String25, error) {
-	regex := `[0-9a-zA-Z\.,:;/#\-_ ]{1,25}`
-	if ok, _ := regexp.MatchString(regex, string25); ok {
-		return String25(string25), nil
+	if !string25Pattern.MatchString(string25) {
+		return "", patternError("string25", string25, string25Pattern)
 	}
-	return "", errors.New("invalid String25")
+	return String25(string25), nil
 }
 
 type DateTimeType string
 
+// DateTimeLayout is the layout of dateTime attributes. The schema requires
+// the offset of the time zone, UTC is +00:00 instead of Z.
+const DateTimeLayout = "2006-01-02T15:04:05-07:00"
+
 func NewDateTimeType(dateTime time.Time) DateTimeType {
-	return DateTimeType(dateTime.Format(time.RFC3339))
+	return DateTimeType(dateTime.Format(DateTimeLayout))
 }
 
 type CastkaType string
 
-func NewCastkaType(castka float64) (CastkaType, error) {
-	strCastka := fmt.Sprintf("%0.2f", castka)
-
-	regex := `((0|-?[1-9]\d{0,7})\.\d\d|-0\.(0[1-9]|[1-9]\d))`
-	if ok, _ := regexp.MatchString(regex, strCastka); ok {
-		return CastkaType(strCastka), nil
+func NewCastkaType(castka Castka) (CastkaType, error) {
+	strCastka := castka.String()
+	if !castkaPattern.MatchString(strCastka) {
+		return "0.00", patternError("CastkaType", strCastka, castkaPattern)
 	}
-	return "0.00", errors.New("invalid CastkaType")
+	return CastkaType(strCastka), nil
+}
+
+// NewCastkaTypeFromFloat is like NewCastkaType for an amount in float64.
+//
+// Deprecated: use NewCastkaType with an exact Castka.
+func NewCastkaTypeFromFloat(castka float64) (CastkaType, error) {
+	return NewCastkaType(CastkaFromFloat(castka))
 }
 
 type IdProvozType int32
 
 func NewIdProvozType(idProvozType int) (IdProvozType, error) {
-	if 0 < idProvozType && idProvozType < 1000000 {
-		return IdProvozType(idProvozType), nil
+	if idProvozType < 1 || idProvozType > 999999 {
+		return 0, &ValidationError{Type: "IdProvozType", Value: fmt.Sprint(idProvozType), Constraint: "must be between 1 and 999999"}
 	}
-	return 0, errors.New("invalid IdProvozType")
+	return IdProvozType(idProvozType), nil
 }
 
 func (i IdProvozType) String() string {
@@ -73,23 +125,19 @@ const (
 type UUIDType string
 
 func NewUUIDType(uuid string) (UUIDType, error) {
-	regex := `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[1-5][0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}`
-	if ok, _ := regexp.MatchString(regex, uuid); ok {
-		return UUIDType(uuid), nil
+	if !uuidPattern.MatchString(uuid) {
+		return "", patternError("UUIDType", uuid, uuidPattern)
 	}
-	return "", errors.New("invalid UUIDType")
-
+	return UUIDType(uuid), nil
 }
 
 type CZDICType string
 
 func NewCZDICType(dic string) (CZDICType, error) {
-	regex := `CZ[0-9]{8,10}`
-	if ok, _ := regexp.MatchString(regex, dic); ok {
-		return CZDICType(dic), nil
+	if !czDicPattern.MatchString(dic) {
+		return "", patternError("CZDICType", dic, czDicPattern)
 	}
-	return "", errors.New("invalid CZDICType")
-
+	return CZDICType(dic), nil
 }
 
 type PkpDigestType string
@@ -0,0 +1,46 @@
+package eet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimpleTypes_Anchored(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"DIC with suffix", func() error { _, err := NewCZDICType("CZ12345678 garbage"); return err }},
+		{"DIC with prefix", func() error { _, err := NewCZDICType("xCZ12345678"); return err }},
+		{"String20 too long", func() error { _, err := NewString20(strings.Repeat("a", 40)); return err }},
+		{"String25 invalid character", func() error { _, err := NewString25("abc*"); return err }},
+		{"UUID with suffix", func() error { _, err := NewUUIDType("b3a09b52-7c87-4014-a496-4c7a53cf9125x"); return err }},
+		{"Castka too large", func() error { _, err := NewCastkaType(CastkaFromKoruny(1000000000)); return err }},
+		{"IdProvoz out of range", func() error { _, err := NewIdProvozType(1000000); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var vErr *ValidationError
+			if err := tt.fn(); !errors.As(err, &vErr) {
+				t.Errorf("expected ValidationError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReceipt_TrzbaValidationField(t *testing.T) {
+	_, err := Receipt{
+		UuidZpravy: "b3a09b52-7c87-4014-a496-4c7a53cf9125",
+		DicPopl:    "CZ00000019",
+		IdProvoz:   273,
+		IdPokl:     strings.Repeat("1", 21),
+		PoradCis:   "1",
+		DatTrzby:   time.Now(),
+	}.Trzba(nil)
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) || vErr.Field != "id_pokl" {
+		t.Fatalf("expected ValidationError of id_pokl, got %v", err)
+	}
+}
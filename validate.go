@@ -0,0 +1,110 @@
+package eet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VAT rates in percent applied to ZaklDan1, ZaklDan2 and ZaklDan3.
+const (
+	RateBasic         = 21
+	RateFirstReduced  = 15
+	RateSecondReduced = 10
+)
+
+// TaxTolerance is the accepted difference between a tax amount and the
+// VAT rate applied to its base, covering rounding to whole crowns.
+const TaxTolerance Castka = 100
+
+// ClockSkewTolerance is how far in the future dat_trzby may be, covering
+// clocks of cash registers running ahead of the clock of the Dispatcher.
+const ClockSkewTolerance = 5 * time.Minute
+
+// ReceiptErrors lists all problems found in a receipt.
+type ReceiptErrors []error
+
+func (e ReceiptErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks that the amounts, DICs and dates of the receipt are
+// consistent with each other, catching mistakes the server would otherwise
+// report only as warnings. All problems are reported at once as ReceiptErrors.
+func (r Receipt) Validate() error {
+	return r.ValidateAt(time.Now())
+}
+
+// ValidateAt is like Validate with dat_trzby compared to now instead of the
+// current time. dat_trzby may be up to ClockSkewTolerance after now.
+func (r Receipt) ValidateAt(now time.Time) error {
+	var errs ReceiptErrors
+
+	items := []Castka{
+		r.ZaklNepodlDph,
+		r.ZaklDan1, r.Dan1,
+		r.ZaklDan2, r.Dan2,
+		r.ZaklDan3, r.Dan3,
+		r.CestSluz,
+		r.PouzitZboz1, r.PouzitZboz2, r.PouzitZboz3,
+		r.UrcenoCerpZuct, r.CerpZuct,
+	}
+	itemized := false
+	for _, item := range items {
+		itemized = itemized || item != 0
+	}
+	if sum := SumCastka(items...); itemized && sum != r.CelkTrzba {
+		errs = append(errs, fmt.Errorf("celk_trzba %s does not equal the sum of bases, taxes and other amounts %s", r.CelkTrzba, sum))
+	}
+
+	taxes := []struct {
+		base, tax         Castka
+		baseName, taxName string
+		rate              int64
+	}{
+		{r.ZaklDan1, r.Dan1, "zakl_dan1", "dan1", RateBasic},
+		{r.ZaklDan2, r.Dan2, "zakl_dan2", "dan2", RateFirstReduced},
+		{r.ZaklDan3, r.Dan3, "zakl_dan3", "dan3", RateSecondReduced},
+	}
+	for _, t := range taxes {
+		if t.base != 0 && t.tax != 0 && (t.base < 0) != (t.tax < 0) {
+			errs = append(errs, fmt.Errorf("%s %s and %s %s have opposite signs", t.baseName, t.base, t.taxName, t.tax))
+			continue
+		}
+		expected, err := t.base.PercentChecked(t.rate)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", t.baseName, t.base, err))
+			continue
+		}
+		if t.tax.Sub(expected).Abs() > TaxTolerance {
+			errs = append(errs, fmt.Errorf("%s %s is not %d %% of %s %s (%s)", t.taxName, t.tax, t.rate, t.baseName, t.base, expected))
+		}
+	}
+	if r.CelkTrzba < 0 {
+		for _, t := range taxes {
+			if t.base > 0 || t.tax > 0 {
+				errs = append(errs, fmt.Errorf("refund with negative celk_trzba %s has positive %s or %s", r.CelkTrzba, t.baseName, t.taxName))
+			}
+		}
+	}
+
+	if r.DicPoverujiciho != "" && r.DicPoverujiciho == r.DicPopl {
+		errs = append(errs, fmt.Errorf("dic_poverujiciho must differ from dic_popl %s", r.DicPopl))
+	}
+
+	if r.DatTrzby.IsZero() {
+		errs = append(errs, errors.New("dat_trzby is missing"))
+	} else if r.DatTrzby.After(now.Add(ClockSkewTolerance)) {
+		errs = append(errs, fmt.Errorf("dat_trzby %s is in the future", r.DatTrzby.Format(time.RFC3339)))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package eet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReceipt_Validate(t *testing.T) {
+	now := time.Now()
+	valid := Receipt{
+		DicPopl:   "CZ00000019",
+		DatTrzby:  now.Add(-time.Minute),
+		CelkTrzba: MustParseCastka("1331.00"),
+		ZaklDan1:  MustParseCastka("1000.00"),
+		Dan1:      MustParseCastka("210.00"),
+		ZaklDan3:  MustParseCastka("110.00"),
+		Dan3:      MustParseCastka("11.00"),
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *Receipt)
+		// want are the substrings of the expected problems, none when empty
+		want []string
+	}{
+		{name: "valid", modify: func(r *Receipt) {}},
+		{
+			name: "sum",
+			modify: func(r *Receipt) {
+				r.CelkTrzba = MustParseCastka("1330.00")
+			},
+			want: []string{"celk_trzba 1330.00 does not equal the sum"},
+		},
+		{
+			name: "tax within tolerance",
+			modify: func(r *Receipt) {
+				r.Dan1 = MustParseCastka("211.00")
+				r.CelkTrzba = MustParseCastka("1332.00")
+			},
+		},
+		{
+			name: "tax beyond tolerance",
+			modify: func(r *Receipt) {
+				r.Dan1 = MustParseCastka("211.01")
+				r.CelkTrzba = MustParseCastka("1332.01")
+			},
+			want: []string{"dan1 211.01 is not 21 % of zakl_dan1"},
+		},
+		{
+			name: "reduced rates",
+			modify: func(r *Receipt) {
+				r.ZaklDan2 = MustParseCastka("100.00")
+				r.Dan2 = MustParseCastka("10.00")
+				r.Dan3 = MustParseCastka("16.50")
+				r.CelkTrzba = MustParseCastka("1446.50")
+			},
+			want: []string{"dan2 10.00 is not 15 % of zakl_dan2", "dan3 16.50 is not 10 % of zakl_dan3"},
+		},
+		{
+			name: "opposite signs",
+			modify: func(r *Receipt) {
+				r.Dan1 = MustParseCastka("-210.00")
+				r.CelkTrzba = MustParseCastka("911.00")
+			},
+			want: []string{"zakl_dan1 1000.00 and dan1 -210.00 have opposite signs"},
+		},
+		{
+			name: "refund",
+			modify: func(r *Receipt) {
+				r.CelkTrzba = MustParseCastka("-1331.00")
+				r.ZaklDan1, r.Dan1 = r.ZaklDan1.Neg(), r.Dan1.Neg()
+				r.ZaklDan3, r.Dan3 = r.ZaklDan3.Neg(), r.Dan3.Neg()
+			},
+		},
+		{
+			name: "refund with positive tax",
+			modify: func(r *Receipt) {
+				r.CelkTrzba = MustParseCastka("-100.00")
+				r.ZaklDan3, r.Dan3 = 0, 0
+				r.ZaklNepodlDph = MustParseCastka("-1310.00")
+			},
+			want: []string{"refund with negative celk_trzba -100.00 has positive zakl_dan1 or dan1"},
+		},
+		{
+			name: "dic_poverujiciho",
+			modify: func(r *Receipt) {
+				r.DicPoverujiciho = r.DicPopl
+			},
+			want: []string{"dic_poverujiciho must differ"},
+		},
+		{
+			name: "missing dat_trzby",
+			modify: func(r *Receipt) {
+				r.DatTrzby = time.Time{}
+			},
+			want: []string{"dat_trzby is missing"},
+		},
+		{
+			name: "dat_trzby within clock skew",
+			modify: func(r *Receipt) {
+				r.DatTrzby = now.Add(ClockSkewTolerance)
+			},
+		},
+		{
+			name: "dat_trzby in the future",
+			modify: func(r *Receipt) {
+				r.DatTrzby = now.Add(ClockSkewTolerance + time.Second)
+			},
+			want: []string{"is in the future"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+			err := r.ValidateAt(now)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var errs ReceiptErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected ReceiptErrors, got %v", err)
+			}
+			if len(errs) != len(tt.want) {
+				t.Fatalf("expected %d problems, got %d: %v", len(tt.want), len(errs), errs)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(errs[i].Error(), want) {
+					t.Errorf("expected problem %q, got %q", want, errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReceipt_ValidateAll(t *testing.T) {
+	now := time.Now()
+	r := Receipt{
+		DicPopl:         "CZ00000019",
+		DicPoverujiciho: "CZ00000019",
+		DatTrzby:        now.Add(time.Hour),
+		CelkTrzba:       MustParseCastka("1330.00"),
+		ZaklDan1:        MustParseCastka("1000.00"),
+		Dan1:            MustParseCastka("150.00"),
+	}
+	var errs ReceiptErrors
+	if !errors.As(r.ValidateAt(now), &errs) {
+		t.Fatal("expected ReceiptErrors")
+	}
+	if len(errs) != 4 {
+		t.Errorf("expected all 4 problems at once, got %d: %v", len(errs), errs)
+	}
+}
@@ -0,0 +1,257 @@
+package eet
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrMissingSignature is wrapped by SignatureError when a message carries no WS-Security signature at all.
+var ErrMissingSignature = errors.New("signature missing")
+
+// SignatureError is returned when the WS-Security signature of a message is
+// missing, malformed, does not match the signed content or was not made by
+// a trusted certificate. Content of such a message must not be trusted.
+type SignatureError struct {
+	Reason string
+	Err    error
+}
+
+func (e *SignatureError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("eet: invalid signature: %s: %v", e.Reason, e.Err)
+	}
+	return "eet: invalid signature: " + e.Reason
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+func signatureError(reason string, err error) error {
+	return &SignatureError{Reason: reason, Err: err}
+}
+
+// VerifySignature checks the WS-Security signature of the SOAP envelope.
+// The signature has to cover the SOAP Body using exclusive canonicalization
+// and rsa-sha256, and the signing certificate has to chain to roots.
+// System roots are used when roots is nil. On success the signing
+// certificate and the signed Body in exclusive canonical form are returned,
+// otherwise the error is a *SignatureError. Only the returned Body is covered
+// by the signature, the content of the message must be decoded from it.
+func VerifySignature(envelope []byte, roots *x509.CertPool) (*x509.Certificate, []byte, error) {
+	root, err := parseXMLTree(envelope)
+	if err != nil {
+		return nil, nil, signatureError("malformed XML", err)
+	}
+	if root.space != NsSoapEnvUrl || root.local != "Envelope" {
+		return nil, nil, signatureError("document is not a SOAP envelope", nil)
+	}
+	bodies := root.childrenNamed(NsSoapEnvUrl, "Body")
+	if len(bodies) != 1 {
+		return nil, nil, signatureError("envelope must contain exactly one Body", nil)
+	}
+	body := bodies[0]
+
+	var security, signature *xmlNode
+	if header := root.child(NsSoapEnvUrl, "Header"); header != nil {
+		security = header.child(NsWsseUrl, "Security")
+	}
+	if security != nil {
+		signature = security.child(NsDsUrl, "Signature")
+	}
+	if signature == nil {
+		return nil, nil, signatureError("no ds:Signature in wsse:Security header", ErrMissingSignature)
+	}
+
+	signedInfo := signature.child(NsDsUrl, "SignedInfo")
+	if signedInfo == nil {
+		return nil, nil, signatureError("missing ds:SignedInfo", nil)
+	}
+	c14nMethod := signedInfo.child(NsDsUrl, "CanonicalizationMethod")
+	if c14nMethod == nil {
+		return nil, nil, signatureError("missing ds:CanonicalizationMethod", nil)
+	}
+	if alg, _ := c14nMethod.attr("", "Algorithm"); alg != AlgorithmC14N {
+		return nil, nil, signatureError(fmt.Sprintf("unsupported canonicalization method %q", alg), nil)
+	}
+	if sigMethod := signedInfo.child(NsDsUrl, "SignatureMethod"); sigMethod == nil {
+		return nil, nil, signatureError("missing ds:SignatureMethod", nil)
+	} else if alg, _ := sigMethod.attr("", "Algorithm"); alg != AlgorithmSHA256 {
+		return nil, nil, signatureError(fmt.Sprintf("unsupported signature method %q", alg), nil)
+	}
+
+	references := signedInfo.childrenNamed(NsDsUrl, "Reference")
+	if len(references) != 1 {
+		return nil, nil, signatureError("exactly one ds:Reference expected", nil)
+	}
+	reference := references[0]
+	uri, _ := reference.attr("", "URI")
+	if !strings.HasPrefix(uri, "#") {
+		return nil, nil, signatureError(fmt.Sprintf("unsupported reference URI %q", uri), nil)
+	}
+	targets := root.findByID(uri[1:])
+	if len(targets) != 1 || targets[0] != body {
+		return nil, nil, signatureError("signature does not cover the SOAP Body", nil)
+	}
+
+	var prefixes []string
+	if transforms := reference.child(NsDsUrl, "Transforms"); transforms != nil {
+		for _, transform := range transforms.childrenNamed(NsDsUrl, "Transform") {
+			if alg, _ := transform.attr("", "Algorithm"); alg != AlgorithmC14N {
+				return nil, nil, signatureError(fmt.Sprintf("unsupported transform %q", alg), nil)
+			}
+			prefixes = inclusivePrefixes(transform)
+		}
+	}
+	if digestMethod := reference.child(NsDsUrl, "DigestMethod"); digestMethod == nil {
+		return nil, nil, signatureError("missing ds:DigestMethod", nil)
+	} else if alg, _ := digestMethod.attr("", "Algorithm"); alg != AlgorithmDigestSHA256 {
+		return nil, nil, signatureError(fmt.Sprintf("unsupported digest method %q", alg), nil)
+	}
+	digestValue := reference.child(NsDsUrl, "DigestValue")
+	if digestValue == nil {
+		return nil, nil, signatureError("missing ds:DigestValue", nil)
+	}
+	expectedDigest, err := decodeBase64Text(digestValue.text())
+	if err != nil {
+		return nil, nil, signatureError("decoding ds:DigestValue", err)
+	}
+	signedBody := excC14N(body, prefixes)
+	digest := sha256.Sum256(signedBody)
+	if subtle.ConstantTimeCompare(digest[:], expectedDigest) != 1 {
+		return nil, nil, signatureError("digest of the SOAP Body does not match", nil)
+	}
+
+	certificate, intermediates, err := signingCertificate(security, signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := certificate.Verify(opts); err != nil {
+		return nil, nil, signatureError("untrusted signing certificate", err)
+	}
+
+	signatureValue := signature.child(NsDsUrl, "SignatureValue")
+	if signatureValue == nil {
+		return nil, nil, signatureError("missing ds:SignatureValue", nil)
+	}
+	signatureBytes, err := decodeBase64Text(signatureValue.text())
+	if err != nil {
+		return nil, nil, signatureError("decoding ds:SignatureValue", err)
+	}
+	signed := excC14N(signedInfo, inclusivePrefixes(c14nMethod))
+	if err := certificate.CheckSignature(x509.SHA256WithRSA, signed, signatureBytes); err != nil {
+		return nil, nil, signatureError("signature value does not match ds:SignedInfo", err)
+	}
+
+	return certificate, signedBody, nil
+}
+
+// signingCertificate returns the certificate referenced from the signature's
+// KeyInfo and the remaining binary security tokens as possible intermediates.
+func signingCertificate(security, signature *xmlNode) (*x509.Certificate, *x509.CertPool, error) {
+	tokens := security.childrenNamed(NsWsseUrl, "BinarySecurityToken")
+	if len(tokens) == 0 {
+		return nil, nil, signatureError("missing wsse:BinarySecurityToken", nil)
+	}
+	token := tokens[0]
+	if keyInfo := signature.child(NsDsUrl, "KeyInfo"); keyInfo != nil {
+		if str := keyInfo.child(NsWsseUrl, "SecurityTokenReference"); str != nil {
+			if ref := str.child(NsWsseUrl, "Reference"); ref != nil {
+				uri, _ := ref.attr("", "URI")
+				token = nil
+				for _, t := range tokens {
+					if id, ok := t.id(); ok && "#"+id == uri {
+						token = t
+					}
+				}
+				if token == nil {
+					return nil, nil, signatureError(fmt.Sprintf("referenced security token %q not found", uri), nil)
+				}
+			}
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	var certificate *x509.Certificate
+	for _, t := range tokens {
+		raw, err := decodeBase64Text(t.text())
+		if err != nil {
+			return nil, nil, signatureError("decoding wsse:BinarySecurityToken", err)
+		}
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, nil, signatureError("parsing signing certificate", err)
+		}
+		if t == token {
+			certificate = cert
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	return certificate, intermediates, nil
+}
+
+// inclusivePrefixes returns the PrefixList of the ec:InclusiveNamespaces child of n.
+func inclusivePrefixes(n *xmlNode) []string {
+	in := n.child(NsEcUrl, "InclusiveNamespaces")
+	if in == nil {
+		return nil
+	}
+	list, _ := in.attr("", "PrefixList")
+	return strings.Fields(list)
+}
+
+func decodeBase64Text(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, s)
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// LoadCertPool reads PEM or DER encoded certificates from files into a new
+// pool, e.g. the EET CA certificate used to verify responses.
+func LoadCertPool(paths ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading file %s: %w", path, err)
+		}
+		if !strings.Contains(string(data), "-----BEGIN") {
+			cert, err := x509.ParseCertificate(data)
+			if err != nil {
+				return nil, fmt.Errorf("parsing certificate %s: %w", path, err)
+			}
+			pool.AddCert(cert)
+			continue
+		}
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parsing certificate %s: %w", path, err)
+			}
+			pool.AddCert(cert)
+		}
+	}
+	return pool, nil
+}
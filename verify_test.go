@@ -0,0 +1,194 @@
+package eet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "EET CA Test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca *testCA) newSigner(t *testing.T, cn string) *Signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewCryptoSigner(key, cert, ca.cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+type testOdpoved struct {
+	XMLName  xml.Name `xml:"http://fs.mfcr.cz/eet/schema/v3 Odpoved"`
+	Hlavicka struct {
+		UuidZpravy string `xml:"uuid_zpravy,attr"`
+	} `xml:"Hlavicka"`
+	Potvrzeni struct {
+		Fik string `xml:"fik,attr"`
+	} `xml:"Potvrzeni"`
+}
+
+func signedTestEnvelope(t *testing.T, signer *Signer, fik string) []byte {
+	t.Helper()
+	var odpoved testOdpoved
+	odpoved.Hlavicka.UuidZpravy = "b3a09b52-7c87-4014-a496-4c7a53cf9125"
+	odpoved.Potvrzeni.Fik = fik
+	envelope, err := NewSOAPEnvelopeRequest(odpoved, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := xml.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestVerifySignature(t *testing.T) {
+	ca := newTestCA(t)
+	signer := ca.newSigner(t, "EET server")
+	fik := "b3309b52-7c87-4014-a496-4c7a53cf9125-fa"
+	envelope := signedTestEnvelope(t, signer, fik)
+
+	cert, body, err := VerifySignature(envelope, ca.pool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "EET server" {
+		t.Errorf("unexpected signing certificate %s", cert.Subject)
+	}
+	var response SOAPEnvelopeResponse
+	if err := xml.Unmarshal(body, &response.Body); err != nil {
+		t.Fatal(err)
+	}
+	if response.Body.Odpoved.Potvrzeni == nil || response.Body.Odpoved.Potvrzeni.Fik != fik {
+		t.Errorf("unexpected signed body %s", body)
+	}
+
+	tests := []struct {
+		name     string
+		envelope []byte
+		roots    *x509.CertPool
+		missing  bool
+	}{
+		{
+			name:     "tampered body",
+			envelope: bytes.Replace(envelope, []byte(fik), []byte("00000000-7c87-4014-a496-4c7a53cf9125-fa"), 1),
+			roots:    ca.pool(),
+		},
+		{
+			name:     "untrusted certificate",
+			envelope: envelope,
+			roots:    newTestCA(t).pool(),
+		},
+		{
+			name:     "missing signature",
+			envelope: []byte(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body></soap:Body></soap:Envelope>`),
+			roots:    ca.pool(),
+			missing:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := VerifySignature(tt.envelope, tt.roots)
+			var sigErr *SignatureError
+			if !errors.As(err, &sigErr) {
+				t.Fatalf("expected SignatureError, got %v", err)
+			}
+			if errors.Is(err, ErrMissingSignature) != tt.missing {
+				t.Errorf("unexpected ErrMissingSignature match for %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifySignature_WrappedBody(t *testing.T) {
+	ca := newTestCA(t)
+	fik := "b3309b52-7c87-4014-a496-4c7a53cf9125-fa"
+	envelope := signedTestEnvelope(t, ca.newSigner(t, "EET server"), fik)
+
+	// a forged Odpoved in a Body of a foreign namespace after the signed one
+	forged := []byte(`<x:Body xmlns:x="urn:forged"><Odpoved xmlns="http://fs.mfcr.cz/eet/schema/v3">` +
+		`<Hlavicka uuid_zpravy="b3a09b52-7c87-4014-a496-4c7a53cf9125"></Hlavicka>` +
+		`<Potvrzeni fik="00000000-0000-0000-0000-000000000000-00"></Potvrzeni></Odpoved></x:Body>`)
+	i := bytes.LastIndex(envelope, []byte("</"))
+	wrapped := append(append(append([]byte(nil), envelope[:i]...), forged...), envelope[i:]...)
+
+	_, body, err := VerifySignature(wrapped, ca.pool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"signed body": body, "envelope": wrapped} {
+		var response SOAPEnvelopeResponse
+		target := interface{}(&response)
+		if name == "signed body" {
+			target = &response.Body
+		}
+		if err := xml.Unmarshal(data, target); err != nil {
+			t.Fatal(err)
+		}
+		if p := response.Body.Odpoved.Potvrzeni; p == nil || p.Fik != fik {
+			t.Errorf("%s decoded to forged Potvrzeni %+v", name, p)
+		}
+	}
+}
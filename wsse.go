@@ -98,27 +98,61 @@ func NewSOAPEnvelopeRequest(content interface{}, signer *Signer) (SOAPEnvelopeRe
 	envelope.Header.Security.BinarySecurityToken.Value = signer.Base64Cert()
 
 	// DigestValue
-	body, err := xml.Marshal(envelope.Body)
+	reference := &envelope.Header.Security.Signature.SignedInfo.Reference
+	body, err := canonicalEnvelopePart(envelope, func(root *xmlNode) *xmlNode {
+		if nodes := root.findByID(bodyId); len(nodes) == 1 {
+			return nodes[0]
+		}
+		return nil
+	}, reference.Transforms.Transform.InclusiveNamespaces.PrefixList)
 	if err != nil {
-		return SOAPEnvelopeRequest{}, errors.Wrap(err, "Failed to xml.Marshal Body")
+		return SOAPEnvelopeRequest{}, errors.Wrap(err, "Failed to canonicalize Body")
 	}
 	bodySum := sha256.Sum256(body)
-	envelope.Header.Security.Signature.SignedInfo.Reference.DigestValue.Value = base64.StdEncoding.EncodeToString(bodySum[:])
+	reference.DigestValue.Value = base64.StdEncoding.EncodeToString(bodySum[:])
 
 	// SignatureValue
-	signedInfo, err := xml.Marshal(envelope.Header.Security.Signature.SignedInfo)
+	signedInfo, err := canonicalEnvelopePart(envelope, func(root *xmlNode) *xmlNode {
+		if header := root.child(NsSoapEnvUrl, "Header"); header != nil {
+			if security := header.child(NsWsseUrl, "Security"); security != nil {
+				if signature := security.child(NsDsUrl, "Signature"); signature != nil {
+					return signature.child(NsDsUrl, "SignedInfo")
+				}
+			}
+		}
+		return nil
+	}, envelope.Header.Security.Signature.SignedInfo.CanonicalizationMethod.InclusiveNamespaces.PrefixList)
 	if err != nil {
-		return SOAPEnvelopeRequest{}, errors.Wrap(err, "Failed to xml.Marshal Header.Security.Signature.SignedInfo")
+		return SOAPEnvelopeRequest{}, errors.Wrap(err, "Failed to canonicalize Header.Security.Signature.SignedInfo")
 	}
 	signedSignedInfo, err := signer.Sign(signedInfo)
 	if err != nil {
-		return SOAPEnvelopeRequest{}, errors.Wrap(err, "Failed to Sign xml.Marshaled Header.Security.Signature.SignedInfo")
+		return SOAPEnvelopeRequest{}, errors.Wrap(err, "Failed to Sign canonicalized Header.Security.Signature.SignedInfo")
 	}
 	envelope.Header.Security.Signature.SignatureValue.Value = base64.StdEncoding.EncodeToString(signedSignedInfo)
 
 	return envelope, nil
 }
 
+// canonicalEnvelopePart marshals the envelope and returns the exclusive
+// canonicalization of the element selected by find, in the context of the
+// whole envelope as the receiver will see it.
+func canonicalEnvelopePart(envelope SOAPEnvelopeRequest, find func(root *xmlNode) *xmlNode, prefixList string) ([]byte, error) {
+	data, err := xml.Marshal(envelope)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to xml.Marshal SOAPEnvelopeRequest")
+	}
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse marshaled SOAPEnvelopeRequest")
+	}
+	n := find(root)
+	if n == nil {
+		return nil, errors.New("element not found in SOAPEnvelopeRequest")
+	}
+	return excC14N(n, strings.Fields(prefixList)), nil
+}
+
 type SOAPHeader struct {
 	XMLName      xml.Name     `xml:"SOAP-ENV:Header"`
 	XmlnsSoapEnv string       `xml:"xmlns:SOAP-ENV,attr"`
@@ -0,0 +1,682 @@
+package eet
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const nsXSDUrl = "http://www.w3.org/2001/XMLSchema"
+
+// xsdSchema is the subset of XML Schema used by EETSchema and by the
+// official EETXMLSchema.xsd: named and anonymous types, restrictions with
+// facets, attributes with fixed values, sequences, choices and simple content
+// extensions. Annotations are ignored.
+type xsdSchema struct {
+	target       string
+	simpleTypes  map[string]*xsdSimpleType
+	complexTypes map[string]*xsdComplexType
+	elements     map[string]*xsdElement
+}
+
+type xsdSimpleType struct {
+	name           string
+	builtin        string
+	base           *xsdSimpleType
+	patterns       []*regexp.Regexp
+	enumeration    []string
+	minInclusive   *big.Rat
+	maxInclusive   *big.Rat
+	length         int
+	minLength      int
+	maxLength      int
+	collapse       bool
+	totalDigits    int
+	fractionDigits int
+}
+
+type xsdAttribute struct {
+	name     string
+	typ      *xsdSimpleType
+	required bool
+	fixed    *string
+}
+
+type xsdComplexType struct {
+	attributes    []xsdAttribute
+	content       *xsdParticle
+	simpleContent *xsdSimpleType
+}
+
+type xsdParticle struct {
+	kind     string // "element", "sequence" or "choice"
+	element  *xsdElement
+	children []*xsdParticle
+	min, max int // max < 0 means unbounded
+}
+
+type xsdElement struct {
+	name    string
+	simple  *xsdSimpleType
+	complex *xsdComplexType
+}
+
+var (
+	eetSchemaOnce sync.Once
+	eetSchema     *xsdSchema
+	eetSchemaErr  error
+)
+
+func loadEETSchema() (*xsdSchema, error) {
+	eetSchemaOnce.Do(func() {
+		eetSchema, eetSchemaErr = parseXSD([]byte(EETSchema))
+	})
+	return eetSchema, eetSchemaErr
+}
+
+// SchemaErrors lists all violations of EETSchema found in a message,
+// each with Field set to the path of the value.
+type SchemaErrors []*ValidationError
+
+func (e SchemaErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateXML validates an XML document whose root is a Trzba or Odpoved
+// element against EETSchema. All violations are returned at once
+// as SchemaErrors.
+func ValidateXML(data []byte) error {
+	schema, err := loadEETSchema()
+	if err != nil {
+		return fmt.Errorf("loading EET schema: %w", err)
+	}
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return fmt.Errorf("parsing XML: %w", err)
+	}
+	var errs SchemaErrors
+	decl, ok := schema.elements[root.local]
+	if !ok || root.space != schema.target {
+		errs = append(errs, &ValidationError{Field: root.local, Constraint: "is not a global element of the schema"})
+	} else {
+		schema.validateElement(root, decl, root.local, &errs)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateTrzba marshals t and validates it against EETSchema.
+func ValidateTrzba(t Trzba) error {
+	data, err := xml.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("marshaling Trzba: %w", err)
+	}
+	return ValidateXML(data)
+}
+
+func (s *xsdSchema) validateElement(n *xmlNode, decl *xsdElement, path string, errs *SchemaErrors) {
+	if decl.simple != nil {
+		if len(n.attrs) > 0 {
+			*errs = append(*errs, &ValidationError{Field: path, Constraint: "attributes are not allowed"})
+		}
+		if hasChildElements(n) {
+			*errs = append(*errs, &ValidationError{Field: path, Constraint: "child elements are not allowed"})
+		}
+		s.validateValue(decl.simple, n.text(), path, errs)
+		return
+	}
+
+	ct := decl.complex
+	for _, a := range n.attrs {
+		if a.space == "http://www.w3.org/2001/XMLSchema-instance" {
+			continue
+		}
+		var attr *xsdAttribute
+		for i := range ct.attributes {
+			if a.space == "" && ct.attributes[i].name == a.local {
+				attr = &ct.attributes[i]
+			}
+		}
+		if attr == nil {
+			*errs = append(*errs, &ValidationError{Field: path + "@" + a.local, Value: a.value, Constraint: "attribute is not allowed"})
+			continue
+		}
+		if attr.fixed != nil && a.value != *attr.fixed {
+			*errs = append(*errs, &ValidationError{Field: path + "@" + a.local, Type: attr.typ.name, Value: a.value, Constraint: "must be " + *attr.fixed})
+			continue
+		}
+		s.validateValue(attr.typ, a.value, path+"@"+a.local, errs)
+	}
+	for _, attr := range ct.attributes {
+		if _, ok := n.attr("", attr.name); attr.required && !ok {
+			*errs = append(*errs, &ValidationError{Field: path + "@" + attr.name, Constraint: "required attribute is missing"})
+		}
+	}
+
+	if ct.simpleContent != nil {
+		if hasChildElements(n) {
+			*errs = append(*errs, &ValidationError{Field: path, Constraint: "child elements are not allowed"})
+		}
+		s.validateValue(ct.simpleContent, n.text(), path, errs)
+		return
+	}
+	if strings.TrimSpace(n.text()) != "" {
+		*errs = append(*errs, &ValidationError{Field: path, Value: n.text(), Constraint: "text content is not allowed"})
+	}
+	var children []*xmlNode
+	for _, c := range n.children {
+		if e, ok := c.(*xmlNode); ok {
+			children = append(children, e)
+		}
+	}
+	pos := 0
+	if ct.content != nil {
+		pos = s.matchParticle(ct.content, children, 0, path, errs)
+	}
+	for _, c := range children[pos:] {
+		*errs = append(*errs, &ValidationError{Field: path + "/" + c.local, Constraint: "element is not allowed here"})
+	}
+}
+
+func hasChildElements(n *xmlNode) bool {
+	for _, c := range n.children {
+		if _, ok := c.(*xmlNode); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchParticle greedily matches children from pos against p and returns the
+// position after the last matched child.
+func (s *xsdSchema) matchParticle(p *xsdParticle, children []*xmlNode, pos int, path string, errs *SchemaErrors) int {
+	if p.kind == "sequence" && p.min == 1 && p.max == 1 {
+		// a mandatory sequence reports its own missing parts
+		for _, c := range p.children {
+			pos = s.matchParticle(c, children, pos, path, errs)
+		}
+		return pos
+	}
+	count := 0
+	for p.max < 0 || count < p.max {
+		next, ok := s.matchOnce(p, children, pos, path, errs)
+		if !ok {
+			break
+		}
+		pos = next
+		count++
+	}
+	if count < p.min {
+		*errs = append(*errs, &ValidationError{Field: path + "/" + p.describe(), Constraint: "required element is missing"})
+	}
+	return pos
+}
+
+func (s *xsdSchema) matchOnce(p *xsdParticle, children []*xmlNode, pos int, path string, errs *SchemaErrors) (int, bool) {
+	switch p.kind {
+	case "element":
+		if pos >= len(children) || children[pos].local != p.element.name || children[pos].space != s.target {
+			return pos, false
+		}
+		s.validateElement(children[pos], p.element, path+"/"+p.element.name, errs)
+		return pos + 1, true
+	case "choice":
+		for _, c := range p.children {
+			if c.startsWith(children, pos, s.target) {
+				return s.matchParticle(c, children, pos, path, errs), true
+			}
+		}
+		return pos, false
+	default:
+		if len(p.children) > 0 && !p.children[0].startsWith(children, pos, s.target) && p.children[0].min > 0 {
+			return pos, false
+		}
+		for _, c := range p.children {
+			pos = s.matchParticle(c, children, pos, path, errs)
+		}
+		return pos, true
+	}
+}
+
+// startsWith reports whether the child at pos can start the particle.
+func (p *xsdParticle) startsWith(children []*xmlNode, pos int, target string) bool {
+	if pos >= len(children) {
+		return false
+	}
+	switch p.kind {
+	case "element":
+		return children[pos].local == p.element.name && children[pos].space == target
+	default:
+		for _, c := range p.children {
+			if c.startsWith(children, pos, target) {
+				return true
+			}
+			if p.kind == "sequence" && c.min > 0 {
+				return false
+			}
+		}
+		return false
+	}
+}
+
+func (p *xsdParticle) describe() string {
+	if p.kind == "element" {
+		return p.element.name
+	}
+	names := make([]string, len(p.children))
+	for i, c := range p.children {
+		names[i] = c.describe()
+	}
+	sep := ","
+	if p.kind == "choice" {
+		sep = "|"
+	}
+	return "(" + strings.Join(names, sep) + ")"
+}
+
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+
+func (s *xsdSchema) validateValue(t *xsdSimpleType, value, path string, errs *SchemaErrors) {
+	typeName := t.name
+	fail := func(constraint string) {
+		*errs = append(*errs, &ValidationError{Field: path, Type: typeName, Value: value, Constraint: constraint})
+	}
+	primitive := t.primitive()
+	if primitive != "string" {
+		value = strings.TrimSpace(value)
+	}
+	for b := t; b != nil; b = b.base {
+		if b.collapse {
+			value = strings.Join(strings.Fields(value), " ")
+		}
+	}
+	for ; t != nil; t = t.base {
+		for _, p := range t.patterns {
+			if !p.MatchString(value) {
+				fail("does not match pattern " + strings.TrimSuffix(strings.TrimPrefix(p.String(), "^(?:"), ")$"))
+				return
+			}
+		}
+		if len(t.enumeration) > 0 {
+			found := false
+			for _, e := range t.enumeration {
+				found = found || e == value
+			}
+			if !found {
+				fail("must be one of " + strings.Join(t.enumeration, ", "))
+				return
+			}
+		}
+		if t.length > 0 {
+			length := len([]rune(value))
+			if primitive == "base64Binary" {
+				data, _ := base64.StdEncoding.DecodeString(value)
+				length = len(data)
+			}
+			if length != t.length {
+				fail(fmt.Sprintf("length must be %d", t.length))
+				return
+			}
+		}
+		if length := len([]rune(value)); t.minLength > 0 && length < t.minLength {
+			fail(fmt.Sprintf("length must be at least %d", t.minLength))
+			return
+		} else if t.maxLength > 0 && length > t.maxLength {
+			fail(fmt.Sprintf("length must be at most %d", t.maxLength))
+			return
+		}
+		if t.minInclusive != nil || t.maxInclusive != nil || t.totalDigits > 0 || t.fractionDigits > 0 {
+			r, ok := new(big.Rat).SetString(value)
+			if !ok || !decimalPattern.MatchString(value) {
+				fail("is not a number")
+				return
+			}
+			if t.minInclusive != nil && r.Cmp(t.minInclusive) < 0 {
+				fail("must be at least " + t.minInclusive.FloatString(2))
+				return
+			}
+			if t.maxInclusive != nil && r.Cmp(t.maxInclusive) > 0 {
+				fail("must be at most " + t.maxInclusive.FloatString(2))
+				return
+			}
+			digits := strings.TrimLeft(strings.TrimLeft(value, "+-"), "0")
+			whole, frac := digits, ""
+			if i := strings.IndexByte(digits, '.'); i >= 0 {
+				whole, frac = digits[:i], strings.TrimRight(digits[i+1:], "0")
+			}
+			if t.fractionDigits > 0 && len(frac) > t.fractionDigits {
+				fail(fmt.Sprintf("must have at most %d fraction digits", t.fractionDigits))
+				return
+			}
+			if t.totalDigits > 0 && len(whole)+len(frac) > t.totalDigits {
+				fail(fmt.Sprintf("must have at most %d digits", t.totalDigits))
+				return
+			}
+		}
+		if err := validateBuiltin(t.builtin, value); err != "" {
+			fail(err)
+			return
+		}
+	}
+}
+
+// primitive returns the built-in type the type is derived from.
+func (t *xsdSimpleType) primitive() string {
+	for ; t != nil; t = t.base {
+		if t.builtin != "" {
+			return t.builtin
+		}
+	}
+	return ""
+}
+
+var xsdBuiltins = map[string]bool{"string": true, "boolean": true, "int": true, "decimal": true, "dateTime": true, "base64Binary": true}
+
+func validateBuiltin(builtin, value string) string {
+	switch builtin {
+	case "", "string":
+	case "boolean":
+		switch value {
+		case "true", "false", "1", "0":
+		default:
+			return "is not a boolean"
+		}
+	case "int":
+		if _, err := strconv.ParseInt(value, 10, 32); err != nil {
+			return "is not an int"
+		}
+	case "decimal":
+		if !decimalPattern.MatchString(value) {
+			return "is not a decimal"
+		}
+	case "dateTime":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "is not a dateTime"
+		}
+	case "base64Binary":
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			return "is not base64"
+		}
+	}
+	return ""
+}
+
+// parseXSD parses the subset of XML Schema described at xsdSchema.
+func parseXSD(data []byte) (*xsdSchema, error) {
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.space != nsXSDUrl || root.local != "schema" {
+		return nil, fmt.Errorf("not an XML schema")
+	}
+	s := &xsdSchema{
+		simpleTypes:  map[string]*xsdSimpleType{},
+		complexTypes: map[string]*xsdComplexType{},
+		elements:     map[string]*xsdElement{},
+	}
+	s.target, _ = root.attr("", "targetNamespace")
+
+	// types are registered first, so references do not depend on declaration order
+	for _, n := range root.childrenNamed(nsXSDUrl, "simpleType") {
+		name, _ := n.attr("", "name")
+		s.simpleTypes[name] = &xsdSimpleType{name: name}
+	}
+	for _, n := range root.childrenNamed(nsXSDUrl, "complexType") {
+		name, _ := n.attr("", "name")
+		s.complexTypes[name] = &xsdComplexType{}
+	}
+	for _, n := range root.childrenNamed(nsXSDUrl, "simpleType") {
+		name, _ := n.attr("", "name")
+		if err := s.parseSimpleType(n, s.simpleTypes[name]); err != nil {
+			return nil, fmt.Errorf("simpleType %s: %w", name, err)
+		}
+	}
+	for _, n := range root.childrenNamed(nsXSDUrl, "complexType") {
+		name, _ := n.attr("", "name")
+		if err := s.parseComplexType(n, s.complexTypes[name]); err != nil {
+			return nil, fmt.Errorf("complexType %s: %w", name, err)
+		}
+	}
+	for _, n := range root.childrenNamed(nsXSDUrl, "element") {
+		e, err := s.parseElement(n)
+		if err != nil {
+			return nil, fmt.Errorf("element: %w", err)
+		}
+		s.elements[e.name] = e
+	}
+	return s, nil
+}
+
+// resolveSimple returns the simple type referenced by the QName in n's attribute.
+func (s *xsdSchema) resolveSimple(n *xmlNode, qname string) (*xsdSimpleType, error) {
+	space, local, err := resolveQName(n, qname)
+	if err != nil {
+		return nil, err
+	}
+	if space == nsXSDUrl {
+		if !xsdBuiltins[local] {
+			return nil, fmt.Errorf("unsupported built-in type %s", local)
+		}
+		return &xsdSimpleType{name: local, builtin: local}, nil
+	}
+	if t, ok := s.simpleTypes[local]; ok && space == s.target {
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown simple type %s", qname)
+}
+
+func resolveQName(n *xmlNode, qname string) (string, string, error) {
+	prefix, local := "", qname
+	if i := strings.IndexByte(qname, ':'); i >= 0 {
+		prefix, local = qname[:i], qname[i+1:]
+	}
+	space, ok := n.lookup(prefix)
+	if !ok {
+		return "", "", fmt.Errorf("undeclared prefix in %s", qname)
+	}
+	return space, local, nil
+}
+
+func (s *xsdSchema) parseSimpleType(n *xmlNode, t *xsdSimpleType) error {
+	restriction := n.child(nsXSDUrl, "restriction")
+	if restriction == nil {
+		return fmt.Errorf("only restrictions are supported")
+	}
+	baseName, _ := restriction.attr("", "base")
+	base, err := s.resolveSimple(restriction, baseName)
+	if err != nil {
+		return err
+	}
+	t.base = base
+	for _, c := range restriction.children {
+		facet, ok := c.(*xmlNode)
+		if !ok {
+			continue
+		}
+		value, _ := facet.attr("", "value")
+		switch facet.local {
+		case "annotation":
+		case "whiteSpace":
+			t.collapse = value == "collapse"
+		case "pattern":
+			p, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return err
+			}
+			t.patterns = append(t.patterns, p)
+		case "enumeration":
+			t.enumeration = append(t.enumeration, value)
+		case "minInclusive", "maxInclusive":
+			r, ok := new(big.Rat).SetString(value)
+			if !ok {
+				return fmt.Errorf("invalid %s %q", facet.local, value)
+			}
+			if facet.local == "minInclusive" {
+				t.minInclusive = r
+			} else {
+				t.maxInclusive = r
+			}
+		case "length", "minLength", "maxLength", "totalDigits", "fractionDigits":
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", facet.local, value)
+			}
+			switch facet.local {
+			case "length":
+				t.length = v
+			case "minLength":
+				t.minLength = v
+			case "maxLength":
+				t.maxLength = v
+			case "totalDigits":
+				t.totalDigits = v
+			default:
+				t.fractionDigits = v
+			}
+		default:
+			return fmt.Errorf("unsupported facet %s", facet.local)
+		}
+	}
+	return nil
+}
+
+func (s *xsdSchema) parseComplexType(n *xmlNode, t *xsdComplexType) error {
+	attrsParent := n
+	if sc := n.child(nsXSDUrl, "simpleContent"); sc != nil {
+		ext := sc.child(nsXSDUrl, "extension")
+		if ext == nil {
+			return fmt.Errorf("only simpleContent extensions are supported")
+		}
+		baseName, _ := ext.attr("", "base")
+		base, err := s.resolveSimple(ext, baseName)
+		if err != nil {
+			return err
+		}
+		t.simpleContent = base
+		attrsParent = ext
+	}
+	for _, a := range attrsParent.childrenNamed(nsXSDUrl, "attribute") {
+		name, _ := a.attr("", "name")
+		typ, err := s.anonymousSimple(a)
+		if err != nil {
+			return fmt.Errorf("attribute %s: %w", name, err)
+		}
+		use, _ := a.attr("", "use")
+		attr := xsdAttribute{name: name, typ: typ, required: use == "required"}
+		if fixed, ok := a.attr("", "fixed"); ok {
+			attr.fixed = &fixed
+		}
+		t.attributes = append(t.attributes, attr)
+	}
+	for _, kind := range []string{"sequence", "choice"} {
+		if c := n.child(nsXSDUrl, kind); c != nil {
+			p, err := s.parseParticle(c)
+			if err != nil {
+				return err
+			}
+			t.content = p
+		}
+	}
+	return nil
+}
+
+func (s *xsdSchema) parseParticle(n *xmlNode) (*xsdParticle, error) {
+	p := &xsdParticle{kind: n.local, min: 1, max: 1}
+	if v, ok := n.attr("", "minOccurs"); ok {
+		p.min, _ = strconv.Atoi(v)
+	}
+	if v, ok := n.attr("", "maxOccurs"); ok {
+		if v == "unbounded" {
+			p.max = -1
+		} else {
+			p.max, _ = strconv.Atoi(v)
+		}
+	}
+	switch n.local {
+	case "element":
+		e, err := s.parseElement(n)
+		if err != nil {
+			return nil, err
+		}
+		p.element = e
+	case "sequence", "choice":
+		for _, c := range n.children {
+			child, ok := c.(*xmlNode)
+			if !ok || child.space != nsXSDUrl || child.local == "annotation" {
+				continue
+			}
+			cp, err := s.parseParticle(child)
+			if err != nil {
+				return nil, err
+			}
+			p.children = append(p.children, cp)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported particle %s", n.local)
+	}
+	return p, nil
+}
+
+func (s *xsdSchema) parseElement(n *xmlNode) (*xsdElement, error) {
+	name, _ := n.attr("", "name")
+	e := &xsdElement{name: name}
+	if typeName, ok := n.attr("", "type"); ok {
+		space, local, err := resolveQName(n, typeName)
+		if err != nil {
+			return nil, err
+		}
+		if ct, ok := s.complexTypes[local]; ok && space == s.target {
+			e.complex = ct
+			return e, nil
+		}
+		if e.simple, err = s.resolveSimple(n, typeName); err != nil {
+			return nil, fmt.Errorf("element %s: %w", name, err)
+		}
+		return e, nil
+	}
+	if c := n.child(nsXSDUrl, "complexType"); c != nil {
+		e.complex = &xsdComplexType{}
+		if err := s.parseComplexType(c, e.complex); err != nil {
+			return nil, fmt.Errorf("element %s: %w", name, err)
+		}
+		return e, nil
+	}
+	if n.child(nsXSDUrl, "simpleType") != nil {
+		var err error
+		if e.simple, err = s.anonymousSimple(n); err != nil {
+			return nil, fmt.Errorf("element %s: %w", name, err)
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("element %s has no type", name)
+}
+
+// anonymousSimple returns the simple type of an attribute or element given by
+// its type attribute or by an anonymous simpleType child, xs:string if none.
+func (s *xsdSchema) anonymousSimple(n *xmlNode) (*xsdSimpleType, error) {
+	if typeName, ok := n.attr("", "type"); ok {
+		return s.resolveSimple(n, typeName)
+	}
+	if c := n.child(nsXSDUrl, "simpleType"); c != nil {
+		name, _ := n.attr("", "name")
+		t := &xsdSimpleType{name: name}
+		if err := s.parseSimpleType(c, t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	return &xsdSimpleType{name: "string", builtin: "string"}, nil
+}
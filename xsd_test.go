@@ -0,0 +1,227 @@
+package eet
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateTrzba(t *testing.T) {
+	signer := newTestCA(t).newSigner(t, "CZ00000019")
+	trzba, err := Receipt{
+		UuidZpravy:   "b3a09b52-7c87-4014-a496-4c7a53cf9125",
+		PrvniZaslani: true,
+		DicPopl:      "CZ00000019",
+		IdProvoz:     273,
+		IdPokl:       "/5546/RO24",
+		PoradCis:     "0/6460/ZQ42",
+		DatTrzby:     time.Now(),
+		CelkTrzba:    MustParseCastka("121.00"),
+		ZaklDan1:     MustParseCastka("100.00"),
+		Dan1:         MustParseCastka("21.00"),
+	}.Trzba(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateTrzba(trzba); err != nil {
+		t.Fatalf("valid Trzba: %v", err)
+	}
+
+	trzba.Data.IdPokl = String20(strings.Repeat("1", 21))
+	trzba.Data.Rezim = 2
+	trzba.KontrolniKody.Bkp.Encoding = "base64"
+	err = ValidateTrzba(trzba)
+	var errs SchemaErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected SchemaErrors, got %v", err)
+	}
+	var receiptErrs ReceiptErrors
+	if errors.As(err, &receiptErrs) {
+		t.Error("schema violations must not be ReceiptErrors")
+	}
+	fields := map[string]bool{}
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{"Trzba/Data@id_pokl", "Trzba/Data@rezim", "Trzba/KontrolniKody/bkp@encoding"} {
+		if !fields[field] {
+			t.Errorf("missing violation of %s in %v", field, errs)
+		}
+	}
+}
+
+func TestValidateXML_Odpoved(t *testing.T) {
+	valid := `<eet:Odpoved xmlns:eet="http://fs.mfcr.cz/eet/schema/v3">` +
+		`<eet:Hlavicka uuid_zpravy="b3a09b52-7c87-4014-a496-4c7a53cf9125" dat_prij="2016-08-05T00:30:12+02:00"/>` +
+		`<eet:Potvrzeni fik="b3309b52-7c87-4014-a496-4c7a53cf9125-ff" test="true"/>` +
+		`<eet:Varovani kod_varov="1">DIC poplatnika v datove zprave se neshoduje s DIC v certifikatu</eet:Varovani>` +
+		`</eet:Odpoved>`
+	if err := ValidateXML([]byte(valid)); err != nil {
+		t.Fatalf("valid Odpoved: %v", err)
+	}
+	invalid := `<eet:Odpoved xmlns:eet="http://fs.mfcr.cz/eet/schema/v3">` +
+		`<eet:Hlavicka uuid_zpravy="b3a09b52-7c87-4014-a496-4c7a53cf9125"/>` +
+		`<eet:Varovani kod_varov="1">text</eet:Varovani>` +
+		`</eet:Odpoved>`
+	if err := ValidateXML([]byte(invalid)); err == nil {
+		t.Fatal("Odpoved without Potvrzeni or Chyba passed validation")
+	}
+}
+
+func TestValidateTrzba_DateTime(t *testing.T) {
+	signer := newTestCA(t).newSigner(t, "CZ00000019")
+	trzba, err := Receipt{
+		UuidZpravy:   "b3a09b52-7c87-4014-a496-4c7a53cf9125",
+		PrvniZaslani: true,
+		DicPopl:      "CZ00000019",
+		IdProvoz:     273,
+		IdPokl:       "/5546/RO24",
+		PoradCis:     "0/6460/ZQ42",
+		DatTrzby:     time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC),
+	}.Trzba(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trzba.Data.DatTrzby != "2019-03-01T10:00:00+00:00" {
+		t.Errorf("unexpected dat_trzby %s", trzba.Data.DatTrzby)
+	}
+	if err := ValidateTrzba(trzba); err != nil {
+		t.Fatalf("valid Trzba: %v", err)
+	}
+
+	trzba.Data.DatTrzby = "2019-03-01T10:00:00Z"
+	if err := ValidateTrzba(trzba); err == nil {
+		t.Error("dat_trzby without the offset passed validation")
+	}
+}
+
+// TestEETSchema_Official checks that the official EETXMLSchema.xsd, when it
+// is placed to testdata, is supported by the validator and equals EETSchema.
+func TestEETSchema_Official(t *testing.T) {
+	official, err := ioutil.ReadFile(filepath.Join("testdata", "EETXMLSchema.xsd"))
+	if os.IsNotExist(err) {
+		t.Skip("testdata/EETXMLSchema.xsd is not vendored")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := parseXSD(official)
+	if err != nil {
+		t.Fatalf("official schema is not supported: %v", err)
+	}
+	trzba, err := Receipt{
+		UuidZpravy:   "b3a09b52-7c87-4014-a496-4c7a53cf9125",
+		PrvniZaslani: true,
+		DicPopl:      "CZ00000019",
+		IdProvoz:     273,
+		IdPokl:       "/5546/RO24",
+		PoradCis:     "0/6460/ZQ42",
+		DatTrzby:     time.Now(),
+		CelkTrzba:    MustParseCastka("121.00"),
+		ZaklDan1:     MustParseCastka("100.00"),
+		Dan1:         MustParseCastka("21.00"),
+	}.Trzba(newTestCA(t).newSigner(t, "CZ00000019"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := xml.Marshal(trzba)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := parseXMLTree(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs SchemaErrors
+	schema.validateElement(root, schema.elements["Trzba"], "Trzba", &errs)
+	if len(errs) > 0 {
+		t.Errorf("valid Trzba violates the official schema: %v", errs)
+	}
+	if !bytes.Equal(official, []byte(EETSchema)) {
+		t.Error("EETSchema differs from testdata/EETXMLSchema.xsd, run go generate")
+	}
+}
+
+// TestParseXSD_Annotated covers constructs of the official EETXMLSchema.xsd
+// which EETSchema does not use: annotations, anonymous simple types of
+// attributes and elements, fixed values and length facets.
+func TestParseXSD_Annotated(t *testing.T) {
+	schema, err := parseXSD([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:t="urn:test" targetNamespace="urn:test" elementFormDefault="qualified">
+	<xs:annotation><xs:documentation>Schéma</xs:documentation></xs:annotation>
+	<xs:simpleType name="KodType">
+		<xs:annotation><xs:documentation>Kód</xs:documentation></xs:annotation>
+		<xs:restriction base="xs:string">
+			<xs:whiteSpace value="collapse"/>
+			<xs:minLength value="2"/>
+			<xs:maxLength value="4"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:element name="Zprava">
+		<xs:annotation><xs:documentation>Zpráva</xs:documentation></xs:annotation>
+		<xs:complexType>
+			<xs:sequence>
+				<xs:annotation><xs:documentation>Obsah</xs:documentation></xs:annotation>
+				<xs:element name="Pocet">
+					<xs:simpleType>
+						<xs:restriction base="xs:int">
+							<xs:maxInclusive value="10"/>
+						</xs:restriction>
+					</xs:simpleType>
+				</xs:element>
+			</xs:sequence>
+			<xs:attribute name="kod" type="t:KodType" use="required">
+				<xs:annotation><xs:documentation>Kód zprávy</xs:documentation></xs:annotation>
+			</xs:attribute>
+			<xs:attribute name="verze" type="xs:string" fixed="3"/>
+			<xs:attribute name="rezim" use="required">
+				<xs:simpleType>
+					<xs:restriction base="xs:int">
+						<xs:enumeration value="0"/>
+						<xs:enumeration value="1"/>
+					</xs:restriction>
+				</xs:simpleType>
+			</xs:attribute>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		doc    string
+		fields []string
+	}{
+		{"valid", `<Zprava xmlns="urn:test" kod=" AB " verze="3" rezim="1"><Pocet>7</Pocet></Zprava>`, nil},
+		{"too short", `<Zprava xmlns="urn:test" kod="A" rezim="1"><Pocet>7</Pocet></Zprava>`, []string{"Zprava@kod"}},
+		{"too long", `<Zprava xmlns="urn:test" kod="ABCDE" rezim="1"><Pocet>7</Pocet></Zprava>`, []string{"Zprava@kod"}},
+		{"fixed", `<Zprava xmlns="urn:test" kod="AB" verze="2" rezim="1"><Pocet>7</Pocet></Zprava>`, []string{"Zprava@verze"}},
+		{"anonymous types", `<Zprava xmlns="urn:test" kod="AB" rezim="2"><Pocet>11</Pocet></Zprava>`, []string{"Zprava@rezim", "Zprava/Pocet"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := parseXMLTree([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var errs SchemaErrors
+			schema.validateElement(root, schema.elements["Zprava"], "Zprava", &errs)
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("expected violations of %v, got %v", tt.fields, errs)
+			}
+			for i, field := range tt.fields {
+				if errs[i].Field != field {
+					t.Errorf("expected violation of %s, got %v", field, errs[i])
+				}
+			}
+		})
+	}
+}